@@ -0,0 +1,167 @@
+package jnode
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// BinaryEncoding is a way of encoding Binary values as text.
+type BinaryEncoding int
+
+const (
+	// Base64 is standard base64 with padding (RFC 4648), which is
+	// what encoding/json uses for []byte.
+	Base64 BinaryEncoding = iota
+	// Base64Raw is standard base64 without padding.
+	Base64Raw
+	// Base64URL is URL safe base64 with padding.
+	Base64URL
+	// Base64RawURL is URL safe base64 without padding.
+	Base64RawURL
+	// Hex is lower case hexadecimal.
+	Hex
+)
+
+// BinaryCodec encodes Binary Nodes as text, and decodes text back to
+// binary data.  The zero value is standard base64 without a prefix,
+// which is what MarshalJSON, AsText and AsBinary use (and what
+// encoding/json uses for []byte.)
+type BinaryCodec struct {
+	// Encoding is the encoding of the binary data.
+	Encoding BinaryEncoding
+	// Prefix, if not empty, is written before encoded values (e.g.
+	// "base64:"), so they can be recognized by FromJSON.
+	Prefix string
+}
+
+// Encode encodes binary data as text, with the prefix.
+func (c BinaryCodec) Encode(b []byte) string {
+	return c.Prefix + c.Encoding.Encode(b)
+}
+
+// Decode removes the prefix (if present) from text and decodes it.
+func (c BinaryCodec) Decode(s string) ([]byte, error) {
+	return c.Encoding.Decode(strings.TrimPrefix(s, c.Prefix))
+}
+
+// Marshal returns the JSON encoding of n, with Binary values encoded
+// by c.
+func (c BinaryCodec) Marshal(n *Node) ([]byte, error) {
+	return json.Marshal(&Node{c.encodeAll(n.value)})
+}
+
+// encodeAll returns a copy of value with Binary values encoded as text.
+func (c BinaryCodec) encodeAll(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []byte:
+		return c.Encode(v)
+	case *[]interface{}:
+		a := make([]interface{}, len(*v))
+		for i, e := range *v {
+			a[i] = c.encodeAll(e)
+		}
+		return &a
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = c.encodeAll(e)
+		}
+		return m
+	default:
+		return v
+	}
+}
+
+// AsBinary is like Node.AsBinary, but decodes Text Nodes with c.
+func (c BinaryCodec) AsBinary(n *Node) ([]byte, error) {
+	switch n.GetType() {
+	case Binary:
+		return n.value.([]byte), nil
+	case Text:
+		return c.Decode(n.value.(string))
+	default:
+		return nil, fmt.Errorf("Node is not binary")
+	}
+}
+
+// FromJSON is like the FromJSON function, except that strings that
+// start with c.Prefix are decoded into Binary Nodes, so Binary Nodes
+// written by Marshal round trip.  The prefix must not be empty.
+func (c BinaryCodec) FromJSON(data []byte) (*Node, error) {
+	if c.Prefix == "" {
+		return nil, fmt.Errorf("a binary prefix is required")
+	}
+	n, err := FromJSON(data)
+	if err != nil {
+		return n, err
+	}
+	n = Transform(n, func(path Path, e *Node) (*Node, WalkAction) {
+		if s, ok := e.value.(string); ok && strings.HasPrefix(s, c.Prefix) {
+			var b []byte
+			if b, err = c.Decode(s); err != nil {
+				err = fmt.Errorf("invalid binary value at %s: %w", path, err)
+				return e, WalkStop
+			}
+			return NewNode(b), WalkContinue
+		}
+		return e, WalkContinue
+	})
+	if err != nil {
+		return MissingNode, err
+	}
+	return n, nil
+}
+
+// Encode encodes binary data as text.
+func (e BinaryEncoding) Encode(b []byte) string {
+	switch e {
+	case Base64Raw:
+		return base64.RawStdEncoding.EncodeToString(b)
+	case Base64URL:
+		return base64.URLEncoding.EncodeToString(b)
+	case Base64RawURL:
+		return base64.RawURLEncoding.EncodeToString(b)
+	case Hex:
+		return hex.EncodeToString(b)
+	default:
+		return base64.StdEncoding.EncodeToString(b)
+	}
+}
+
+// Decode decodes text to binary data.  The base64 encodings accept
+// text with or without padding.
+func (e BinaryEncoding) Decode(s string) ([]byte, error) {
+	switch e {
+	case Base64URL, Base64RawURL:
+		return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	case Hex:
+		return hex.DecodeString(s)
+	default:
+		return base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+	}
+}
+
+func (e BinaryEncoding) String() string {
+	switch e {
+	case Base64:
+		return "base64"
+	case Base64Raw:
+		return "base64-raw"
+	case Base64URL:
+		return "base64-url"
+	case Base64RawURL:
+		return "base64-raw-url"
+	case Hex:
+		return "hex"
+	default:
+		return fmt.Sprintf("BinaryEncoding(%d)", int(e))
+	}
+}
+
+// encodeBinary encodes b with the default codec.
+func encodeBinary(b []byte) string {
+	return BinaryCodec{}.Encode(b)
+}
@@ -0,0 +1,105 @@
+package jnode
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBinaryCodecs(t *testing.T) {
+	data := []byte{0xfb, 0xff, 0x01}
+	tests := []struct {
+		codec BinaryEncoding
+		json  string
+	}{
+		{Base64, `{"b":"+/8B"}`},
+		{Base64Raw, `{"b":"+/8B"}`},
+		{Base64URL, `{"b":"-_8B"}`},
+		{Base64RawURL, `{"b":"-_8B"}`},
+		{Hex, `{"b":"fbff01"}`},
+	}
+	for _, tc := range tests {
+		c := BinaryCodec{Encoding: tc.codec}
+		n := NewObjectNode().Put("b", data)
+		b, err := c.Marshal(n)
+		if err != nil || string(b) != tc.json {
+			t.Errorf("%s: %s %v", tc.codec, b, err)
+		}
+		if n.Path("b").GetType() != Binary {
+			t.Errorf("%s: marshalling changed the node", tc.codec)
+		}
+		m, err := FromJSON(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b, err := c.AsBinary(m.Path("b")); err != nil || !bytes.Equal(b, data) {
+			t.Errorf("%s: %v %v", tc.codec, b, err)
+		}
+	}
+	n := NewObjectNode().Put("b", data)
+	if s := n.String(); s != `{"b":"+/8B"}` {
+		t.Error(s)
+	}
+	if s := n.Path("b").AsText(); s != "+/8B" {
+		t.Error(s)
+	}
+}
+
+func TestBinaryPadding(t *testing.T) {
+	pi := []byte("3.141")
+	for _, s := range []string{`"My4xNDE="`, `"My4xNDE"`} {
+		n, _ := FromJSON([]byte(s))
+		if b, err := n.AsBinary(); err != nil || !bytes.Equal(b, pi) {
+			t.Error(s, err)
+		}
+	}
+	c := BinaryCodec{Encoding: Base64Raw}
+	if b, _ := c.Marshal(NewNode(pi)); string(b) != `"My4xNDE"` {
+		t.Error(string(b))
+	}
+	if s := c.Encode(pi); s != "My4xNDE" {
+		t.Error(s)
+	}
+	if s := NewNode(pi).String(); s != `"My4xNDE="` {
+		t.Error(s)
+	}
+}
+
+func TestBinaryCodecFromJSON(t *testing.T) {
+	c := BinaryCodec{Prefix: "base64:"}
+	n := NewObjectNode().Put("b", []byte("hi")).Put("s", "text")
+	n.PutArray("a").Append(NewNode([]byte{1}))
+	b, err := c.Marshal(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(b)
+	if s != `{"a":["base64:AQ=="],"b":"base64:aGk=","s":"text"}` {
+		t.Error(s)
+	}
+	m, err := c.FromJSON(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Path("b").GetType() != Binary || m.Path("a").Get(0).GetType() != Binary ||
+		m.Path("s").GetType() != Text {
+		t.Error(m)
+	}
+	if b, err := c.Marshal(m); err != nil || string(b) != s {
+		t.Error(string(b), err)
+	}
+	if b, err := c.AsBinary(NewNode("base64:aGk=")); err != nil || string(b) != "hi" {
+		t.Error(b, err)
+	}
+	if m, err := c.FromJSON([]byte(`"base64:QQ=="`)); err != nil || m.GetType() != Binary || string(m.Unwrap().([]byte)) != "A" {
+		t.Error(m, err)
+	}
+	if _, err := c.FromJSON([]byte(`"base64:!!"`)); err == nil {
+		t.Error("invalid base64 should fail")
+	}
+	if _, err := c.FromJSON([]byte(`{"x":"base64:!!"}`)); err == nil {
+		t.Error("invalid base64 should fail")
+	}
+	if _, err := (BinaryCodec{}).FromJSON([]byte(`{}`)); err == nil {
+		t.Error("empty prefix should fail")
+	}
+}
@@ -0,0 +1,640 @@
+package jnode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// BSON element types
+const (
+	bsonDouble     = 0x01
+	bsonString     = 0x02
+	bsonDocument   = 0x03
+	bsonArray      = 0x04
+	bsonBinary     = 0x05
+	bsonUndefined  = 0x06
+	bsonObjectID   = 0x07
+	bsonBool       = 0x08
+	bsonDateTime   = 0x09
+	bsonNull       = 0x0a
+	bsonRegex      = 0x0b
+	bsonDBPointer  = 0x0c
+	bsonCode       = 0x0d
+	bsonSymbol     = 0x0e
+	bsonCodeScope  = 0x0f
+	bsonInt32      = 0x10
+	bsonTimestamp  = 0x11
+	bsonInt64      = 0x12
+	bsonDecimal128 = 0x13
+	bsonMaxKey     = 0x7f
+	bsonMinKey     = 0xff
+)
+
+// maxBSONDepth limits the nesting of decoded BSON documents.
+const maxBSONDepth = 1000
+
+// bsonValue is a non-container BSON value.  Which fields are used
+// depends on kind:
+//
+//	bsonDouble                          f
+//	bsonString, bsonCode, bsonSymbol    s
+//	bsonObjectID                        s (24 hex digits)
+//	bsonBinary                          b, sub
+//	bsonBool, bsonInt32, bsonInt64      i
+//	bsonDateTime                        i (milliseconds since the epoch)
+//	bsonTimestamp                       i (seconds << 32 | increment)
+//	bsonRegex                           s (pattern), s2 (options)
+//	bsonDBPointer                       s (namespace), s2 (ObjectId)
+//	bsonCodeScope                       s, scope
+//	bsonDecimal128                      s (decimal string)
+type bsonValue struct {
+	kind  byte
+	s, s2 string
+	i     int64
+	f     float64
+	b     []byte
+	sub   byte
+	scope map[string]interface{}
+}
+
+// MarshalBSON encodes an Object Node as a BSON document.  Fields are
+// written in sorted order.  int64 values are always written as int64,
+// so the int64 values FromBSON returns keep their type.  Other
+// integers are written as int32 if they fit and as int64 otherwise.
+// Floats are written as double and Binary Nodes as binary (subtype
+// 0.)  Objects in the Extended JSON form of other BSON types (e.g.
+// {"$oid": "..."} or {"$date": {"$numberLong": "..."}}) are written as
+// that type, so documents read with FromBSON using KeepWrappers round
+// trip exactly.
+func (n *Node) MarshalBSON() ([]byte, error) {
+	m, ok := n.value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("bson: only Object nodes can be encoded as documents")
+	}
+	e := &bsonEncoder{}
+	if err := e.document(m); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+type bsonEncoder struct {
+	buf bytes.Buffer
+}
+
+func (e *bsonEncoder) int32(i int32) {
+	e.buf.Write(binary.LittleEndian.AppendUint32(nil, uint32(i)))
+}
+
+func (e *bsonEncoder) int64(i int64) {
+	e.buf.Write(binary.LittleEndian.AppendUint64(nil, uint64(i)))
+}
+
+func (e *bsonEncoder) cstring(s string) error {
+	if strings.IndexByte(s, 0) >= 0 {
+		return fmt.Errorf("bson: %q contains a NUL byte", s)
+	}
+	e.buf.WriteString(s)
+	e.buf.WriteByte(0)
+	return nil
+}
+
+func (e *bsonEncoder) string(s string) {
+	e.int32(int32(len(s) + 1))
+	e.buf.WriteString(s)
+	e.buf.WriteByte(0)
+}
+
+// begin reserves space for a length and returns its offset for end.
+func (e *bsonEncoder) begin() int {
+	start := e.buf.Len()
+	e.int32(0)
+	return start
+}
+
+func (e *bsonEncoder) end(start int) error {
+	size := e.buf.Len() - start
+	if size > math.MaxInt32 {
+		return errors.New("bson: document is too large")
+	}
+	binary.LittleEndian.PutUint32(e.buf.Bytes()[start:], uint32(size))
+	return nil
+}
+
+func (e *bsonEncoder) document(m map[string]interface{}) error {
+	start := e.begin()
+	for _, k := range sortedKeys(m) {
+		if err := e.element(k, m[k]); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteByte(0)
+	return e.end(start)
+}
+
+func (e *bsonEncoder) array(a []interface{}) error {
+	start := e.begin()
+	for i, v := range a {
+		if err := e.element(strconv.Itoa(i), v); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteByte(0)
+	return e.end(start)
+}
+
+func (e *bsonEncoder) element(key string, value interface{}) error {
+	var kind byte
+	switch v := value.(type) {
+	case *[]interface{}:
+		kind = bsonArray
+	case map[string]interface{}:
+		if _, ok, _ := parseExtWrapper(v); !ok {
+			kind = bsonDocument
+		}
+	}
+	if kind != 0 {
+		e.buf.WriteByte(kind)
+		if err := e.cstring(key); err != nil {
+			return err
+		}
+		if kind == bsonArray {
+			return e.array(*value.(*[]interface{}))
+		}
+		return e.document(value.(map[string]interface{}))
+	}
+	v, err := toBSONValue(value)
+	if err != nil {
+		return err
+	}
+	e.buf.WriteByte(v.kind)
+	if err := e.cstring(key); err != nil {
+		return err
+	}
+	return e.value(v)
+}
+
+func (e *bsonEncoder) value(v bsonValue) error {
+	switch v.kind {
+	case bsonDouble:
+		e.buf.Write(binary.LittleEndian.AppendUint64(nil, math.Float64bits(v.f)))
+	case bsonString, bsonCode, bsonSymbol:
+		e.string(v.s)
+	case bsonBinary:
+		if v.sub == 0x02 {
+			// the old binary subtype repeats the length
+			e.int32(int32(len(v.b) + 4))
+			e.buf.WriteByte(v.sub)
+			e.int32(int32(len(v.b)))
+		} else {
+			e.int32(int32(len(v.b)))
+			e.buf.WriteByte(v.sub)
+		}
+		e.buf.Write(v.b)
+	case bsonObjectID:
+		b, _ := hex.DecodeString(v.s)
+		e.buf.Write(b)
+	case bsonBool:
+		e.buf.WriteByte(byte(v.i))
+	case bsonDateTime, bsonTimestamp, bsonInt64:
+		e.int64(v.i)
+	case bsonInt32:
+		e.int32(int32(v.i))
+	case bsonRegex:
+		if err := e.cstring(v.s); err != nil {
+			return err
+		}
+		return e.cstring(v.s2)
+	case bsonDBPointer:
+		e.string(v.s)
+		b, _ := hex.DecodeString(v.s2)
+		e.buf.Write(b)
+	case bsonCodeScope:
+		start := e.begin()
+		e.string(v.s)
+		if err := e.document(v.scope); err != nil {
+			return err
+		}
+		return e.end(start)
+	case bsonDecimal128:
+		lo, hi, err := parseDecimal128(v.s)
+		if err != nil {
+			return err
+		}
+		e.int64(int64(lo))
+		e.int64(int64(hi))
+	}
+	return nil
+}
+
+// toBSONValue converts a non-container Node value (or an Extended JSON
+// wrapper Object) to a bsonValue.
+func toBSONValue(value interface{}) (bsonValue, error) {
+	integer := func(i int64) bsonValue {
+		if i >= math.MinInt32 && i <= math.MaxInt32 {
+			return bsonValue{kind: bsonInt32, i: i}
+		}
+		return bsonValue{kind: bsonInt64, i: i}
+	}
+	unsigned := func(u uint64) (bsonValue, error) {
+		if u > math.MaxInt64 {
+			return bsonValue{}, fmt.Errorf("bson: %d is too large for an int64", u)
+		}
+		return integer(int64(u)), nil
+	}
+	switch v := value.(type) {
+	case nil:
+		return bsonValue{kind: bsonNull}, nil
+	case bool:
+		if v {
+			return bsonValue{kind: bsonBool, i: 1}, nil
+		}
+		return bsonValue{kind: bsonBool}, nil
+	case string:
+		return bsonValue{kind: bsonString, s: v}, nil
+	case []byte:
+		return bsonValue{kind: bsonBinary, b: v}, nil
+	case int:
+		return integer(int64(v)), nil
+	case int8:
+		return integer(int64(v)), nil
+	case int16:
+		return integer(int64(v)), nil
+	case int32:
+		return integer(int64(v)), nil
+	case int64:
+		return bsonValue{kind: bsonInt64, i: v}, nil
+	case uint:
+		return unsigned(uint64(v))
+	case uint8:
+		return unsigned(uint64(v))
+	case uint16:
+		return unsigned(uint64(v))
+	case uint32:
+		return unsigned(uint64(v))
+	case uint64:
+		return unsigned(v)
+	case float32:
+		return bsonValue{kind: bsonDouble, f: float64(v)}, nil
+	case float64:
+		return bsonValue{kind: bsonDouble, f: v}, nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return integer(i), nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return bsonValue{}, fmt.Errorf("bson: invalid number %s", v)
+		}
+		return bsonValue{kind: bsonDouble, f: f}, nil
+	case map[string]interface{}:
+		bv, ok, err := parseExtWrapper(v)
+		if err == nil && !ok {
+			err = errors.New("bson: object is not an Extended JSON value")
+		}
+		return bv, err
+	default:
+		return bsonValue{}, fmt.Errorf("bson: %T cannot be encoded", value)
+	}
+}
+
+// FromBSON creates an Object Node from a BSON document.  Numbers,
+// strings, booleans, null, documents, arrays and binary data (subtypes
+// 0 and 2) become the corresponding Node values, ObjectIds become Text
+// (hex) and dates become Text (RFC 3339) unless opts.KeepWrappers is
+// set.  Other BSON types are represented by their canonical Extended
+// JSON Objects, e.g. {"$numberDecimal": "1.5"}.
+func FromBSON(data []byte, opts ExtJSONOptions) (*Node, error) {
+	d := &bsonDecoder{data: data, opts: opts}
+	m, err := d.document(0)
+	if err != nil {
+		return MissingNode, err
+	}
+	if d.pos != len(data) {
+		return MissingNode, fmt.Errorf("bson: %d bytes of extra data after document", len(data)-d.pos)
+	}
+	return &Node{m}, nil
+}
+
+type bsonDecoder struct {
+	data []byte
+	pos  int
+	opts ExtJSONOptions
+}
+
+func (d *bsonDecoder) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("bson: %s at offset %d", fmt.Sprintf(format, args...), d.pos)
+}
+
+func (d *bsonDecoder) read(n int) ([]byte, error) {
+	if n < 0 || n > len(d.data)-d.pos {
+		return nil, d.errorf("unexpected end of data")
+	}
+	b := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return b, nil
+}
+
+func (d *bsonDecoder) int32() (int32, error) {
+	b, err := d.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return int32(binary.LittleEndian.Uint32(b)), nil
+}
+
+func (d *bsonDecoder) int64() (int64, error) {
+	b, err := d.read(8)
+	if err != nil {
+		return 0, err
+	}
+	return int64(binary.LittleEndian.Uint64(b)), nil
+}
+
+func (d *bsonDecoder) cstring() (string, error) {
+	i := bytes.IndexByte(d.data[d.pos:], 0)
+	if i < 0 {
+		return "", d.errorf("unterminated string")
+	}
+	s := string(d.data[d.pos : d.pos+i])
+	d.pos += i + 1
+	return s, nil
+}
+
+func (d *bsonDecoder) string() (string, error) {
+	n, err := d.int32()
+	if err != nil {
+		return "", err
+	}
+	if n < 1 {
+		return "", d.errorf("invalid string length %d", n)
+	}
+	b, err := d.read(int(n))
+	if err != nil {
+		return "", err
+	}
+	if b[n-1] != 0 {
+		return "", d.errorf("unterminated string")
+	}
+	return string(b[:n-1]), nil
+}
+
+func (d *bsonDecoder) objectID() (string, error) {
+	b, err := d.read(12)
+	return hex.EncodeToString(b), err
+}
+
+// elements reads the elements of a document or array, calling
+// fn for each one.
+func (d *bsonDecoder) elements(depth int, fn func(key string, value interface{})) error {
+	if depth > maxBSONDepth {
+		return d.errorf("document is nested too deeply")
+	}
+	start := d.pos
+	size, err := d.int32()
+	if err != nil {
+		return err
+	}
+	if size < 5 || int(size) > len(d.data)-start {
+		return d.errorf("invalid document length %d", size)
+	}
+	end := start + int(size)
+	data := d.data
+	d.data = data[:end]
+	defer func() { d.data = data }()
+	for {
+		kind, err := d.read(1)
+		if err != nil {
+			return err
+		}
+		if kind[0] == 0 {
+			if d.pos != end {
+				return d.errorf("document ended early")
+			}
+			return nil
+		}
+		key, err := d.cstring()
+		if err != nil {
+			return err
+		}
+		v, err := d.value(kind[0], depth)
+		if err != nil {
+			return err
+		}
+		fn(key, v)
+	}
+}
+
+func (d *bsonDecoder) document(depth int) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	err := d.elements(depth, func(key string, value interface{}) {
+		m[key] = value
+	})
+	return m, err
+}
+
+func (d *bsonDecoder) value(kind byte, depth int) (interface{}, error) {
+	var err error
+	v := bsonValue{kind: kind}
+	switch kind {
+	case bsonDouble:
+		var i int64
+		i, err = d.int64()
+		return math.Float64frombits(uint64(i)), err
+	case bsonString:
+		return d.string()
+	case bsonDocument:
+		return d.document(depth + 1)
+	case bsonArray:
+		a := make([]interface{}, 0)
+		err = d.elements(depth+1, func(_ string, value interface{}) {
+			a = append(a, value)
+		})
+		return &a, err
+	case bsonBinary:
+		var n int32
+		var sub []byte
+		if n, err = d.int32(); err != nil {
+			return nil, err
+		}
+		if sub, err = d.read(1); err != nil {
+			return nil, err
+		}
+		v.sub = sub[0]
+		if v.b, err = d.read(int(n)); err != nil {
+			return nil, err
+		}
+		if v.sub == 0x02 {
+			if len(v.b) < 4 || int(binary.LittleEndian.Uint32(v.b)) != len(v.b)-4 {
+				return nil, d.errorf("invalid binary subtype 2 length")
+			}
+			v.b = v.b[4:]
+		}
+		v.b = append([]byte{}, v.b...)
+	case bsonUndefined, bsonNull, bsonMinKey, bsonMaxKey:
+	case bsonObjectID:
+		v.s, err = d.objectID()
+	case bsonBool:
+		var b []byte
+		if b, err = d.read(1); err == nil {
+			if b[0] > 1 {
+				return nil, d.errorf("invalid boolean %d", b[0])
+			}
+			return b[0] == 1, nil
+		}
+	case bsonDateTime, bsonTimestamp, bsonInt64:
+		v.i, err = d.int64()
+	case bsonInt32:
+		var i int32
+		i, err = d.int32()
+		v.i = int64(i)
+	case bsonRegex:
+		if v.s, err = d.cstring(); err == nil {
+			v.s2, err = d.cstring()
+		}
+	case bsonDBPointer:
+		if v.s, err = d.string(); err == nil {
+			v.s2, err = d.objectID()
+		}
+	case bsonCode, bsonSymbol:
+		v.s, err = d.string()
+	case bsonCodeScope:
+		start := d.pos
+		var n int32
+		if n, err = d.int32(); err != nil {
+			return nil, err
+		}
+		if v.s, err = d.string(); err != nil {
+			return nil, err
+		}
+		if v.scope, err = d.document(depth + 1); err != nil {
+			return nil, err
+		}
+		if d.pos-start != int(n) {
+			return nil, d.errorf("invalid code with scope length %d", n)
+		}
+	case bsonDecimal128:
+		var lo, hi int64
+		if lo, err = d.int64(); err == nil {
+			hi, err = d.int64()
+			v.s = formatDecimal128(uint64(lo), uint64(hi))
+		}
+	default:
+		return nil, d.errorf("unknown element type 0x%02x", kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v.nodeValue(d.opts), nil
+}
+
+// formatDecimal128 formats an IEEE 754-2008 128-bit decimal (BID
+// encoding) as a string.
+func formatDecimal128(lo, hi uint64) string {
+	sign := ""
+	if hi>>63 != 0 {
+		sign = "-"
+	}
+	var exp int
+	var coef big.Int
+	if (hi>>61)&3 == 3 {
+		switch (hi >> 58) & 0x1f {
+		case 0x1e:
+			return sign + "Infinity"
+		case 0x1f:
+			return "NaN"
+		}
+		// the implied coefficient is larger than the maximum, so
+		// the value is treated as zero
+		exp = int((hi>>47)&0x3fff) - 6176
+	} else {
+		exp = int((hi>>49)&0x3fff) - 6176
+		coef.SetUint64(hi & (1<<49 - 1))
+		coef.Lsh(&coef, 64)
+		coef.Or(&coef, new(big.Int).SetUint64(lo))
+	}
+	digits := coef.String()
+	adjusted := exp + len(digits) - 1
+	switch {
+	case exp > 0 || adjusted < -6:
+		s := digits[:1]
+		if len(digits) > 1 {
+			s += "." + digits[1:]
+		}
+		return fmt.Sprintf("%s%sE%+d", sign, s, adjusted)
+	case exp == 0:
+		return sign + digits
+	case -exp >= len(digits):
+		return sign + "0." + strings.Repeat("0", -exp-len(digits)) + digits
+	default:
+		p := len(digits) + exp
+		return sign + digits[:p] + "." + digits[p:]
+	}
+}
+
+// parseDecimal128 parses a decimal string into the BID encoding of an
+// IEEE 754-2008 128-bit decimal.  Values that can't be represented
+// exactly are an error.
+func parseDecimal128(s string) (lo, hi uint64, err error) {
+	bad := func() (uint64, uint64, error) {
+		return 0, 0, fmt.Errorf("bson: invalid decimal128 %q", s)
+	}
+	str := s
+	if str != "" && (str[0] == '-' || str[0] == '+') {
+		if str[0] == '-' {
+			hi = 1 << 63
+		}
+		str = str[1:]
+	}
+	switch strings.ToLower(str) {
+	case "inf", "infinity":
+		return 0, hi | 0x1e<<58, nil
+	case "nan":
+		return 0, 0x1f << 58, nil
+	}
+	mant, exp := str, 0
+	if i := strings.IndexAny(str, "eE"); i >= 0 {
+		mant = str[:i]
+		e, err := strconv.Atoi(str[i+1:])
+		if err != nil {
+			return bad()
+		}
+		exp = e
+	}
+	if i := strings.IndexByte(mant, '.'); i >= 0 {
+		exp -= len(mant) - i - 1
+		mant = mant[:i] + mant[i+1:]
+	}
+	if mant == "" || strings.Trim(mant, "0123456789") != "" {
+		return bad()
+	}
+	mant = strings.TrimLeft(mant, "0")
+	// drop trailing zeros that don't fit
+	for len(mant) > 34 && mant[len(mant)-1] == '0' {
+		mant = mant[:len(mant)-1]
+		exp++
+	}
+	if mant == "" {
+		exp = max(min(exp, 6111), -6176)
+	}
+	for exp > 6111 && mant != "" && len(mant) < 34 {
+		mant += "0"
+		exp--
+	}
+	if len(mant) > 34 || exp < -6176 || exp > 6111 {
+		return bad()
+	}
+	var coef big.Int
+	coef.SetString("0"+mant, 10)
+	lo = coef.Uint64()
+	hi |= new(big.Int).Rsh(&coef, 64).Uint64() | uint64(exp+6176)<<49
+	return lo, hi, nil
+}
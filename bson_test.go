@@ -0,0 +1,155 @@
+package jnode
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestBSONRoundTrip(t *testing.T) {
+	n := NewObjectNode().Put("s", "x").Put("i", 5).Put("l", int64(5)).
+		Put("f", 1.5).Put("b", []byte{1, 2}).Put("t", true).Put("z", nil)
+	n.PutArray("a").Append(1).Append("two")
+	n.PutObject("o").Put("k", "v")
+	data, err := n.MarshalBSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := FromBSON(data, ExtJSONOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := m.String(); s != `{"a":[1,"two"],"b":"AQI=","f":1.5,"i":5,"l":5,"o":{"k":"v"},"s":"x","t":true,"z":null}` {
+		t.Error(s)
+	}
+	if _, ok := m.Path("i").Unwrap().(int32); !ok {
+		t.Errorf("%T", m.Path("i").Unwrap())
+	}
+	if _, ok := m.Path("l").Unwrap().(int64); !ok {
+		t.Errorf("%T", m.Path("l").Unwrap())
+	}
+	if m.Path("b").GetType() != Binary {
+		t.Error(m.Path("b").GetType())
+	}
+	again, _ := m.MarshalBSON()
+	if !bytes.Equal(data, again) {
+		t.Error(hex.EncodeToString(again))
+	}
+}
+
+func TestBSONEncoding(t *testing.T) {
+	// {"hello": "world"} from bsonspec.org
+	data, err := NewObjectNode().Put("hello", "world").MarshalBSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := hex.EncodeToString(data); s != "160000000268656c6c6f0006000000776f726c640000" {
+		t.Error(s)
+	}
+	if _, err := NewArrayNode().MarshalBSON(); err == nil {
+		t.Error("expected error for array")
+	}
+	if _, err := NewObjectNode().Put("a\x00", 1).MarshalBSON(); err == nil {
+		t.Error("expected error for NUL in key")
+	}
+	if _, err := NewObjectNode().Put("u", uint64(math.MaxUint64)).MarshalBSON(); err == nil {
+		t.Error("expected error for uint64")
+	}
+	ints := []struct {
+		value interface{}
+		kind  byte
+	}{
+		{5, 0x10},
+		{int32(-5), 0x10},
+		{uint(5), 0x10},
+		{json.Number("5"), 0x10},
+		{math.MaxInt32 + 1, 0x12},
+		{uint64(math.MaxInt64), 0x12},
+		{int64(5), 0x12},
+	}
+	for _, tc := range ints {
+		data, err := NewObjectNode().Put("i", &Node{tc.value}).MarshalBSON()
+		if err != nil || data[4] != tc.kind {
+			t.Errorf("%T %v: % x %v", tc.value, tc.value, data, err)
+		}
+	}
+}
+
+func TestBSONTypes(t *testing.T) {
+	doc := mustJSON(t, `{
+		"_id": {"$oid": "5f1a2b3c4d5e6f7081920a1b"},
+		"when": {"$date": {"$numberLong": "1600000000123"}},
+		"ts": {"$timestamp": {"t": 1, "i": 2}},
+		"re": {"$regularExpression": {"pattern": "^a", "options": "i"}},
+		"dec": {"$numberDecimal": "1.5E+10"},
+		"uuid": {"$binary": {"base64": "AAAAAAAAAAAAAAAAAAAAAA==", "subType": "04"}},
+		"min": {"$minKey": 1},
+		"code": {"$code": "f()", "$scope": {"x": 1}}
+	}`)
+	data, err := doc.MarshalBSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := FromBSON(data, ExtJSONOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := m.Path("_id").AsText(); s != "5f1a2b3c4d5e6f7081920a1b" {
+		t.Error(s)
+	}
+	if s := m.Path("when").AsText(); s != "2020-09-13T12:26:40.123Z" {
+		t.Error(s)
+	}
+	for _, k := range []string{"ts", "re", "dec", "uuid", "min"} {
+		if s, e := m.Path(k).String(), doc.Path(k).String(); s != e {
+			t.Errorf("%s: %s", k, s)
+		}
+	}
+	if s := m.Path("code").String(); s != `{"$code":"f()","$scope":{"x":1}}` {
+		t.Error(s)
+	}
+	m, err = FromBSON(data, ExtJSONOptions{KeepWrappers: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := m.MarshalBSON()
+	if !bytes.Equal(data, again) {
+		t.Error("BSON did not round trip with KeepWrappers")
+	}
+}
+
+func TestDecimal128(t *testing.T) {
+	for _, s := range []string{"0", "-0", "1.5", "0.001", "1E+3", "1.23E-7", "-123456789012345678901234567890123.4",
+		"9.999999999999999999999999999999999E+6144", "1E-6176", "Infinity", "-Infinity", "NaN", "0E+10"} {
+		lo, hi, err := parseDecimal128(s)
+		if err != nil {
+			t.Error(s, err)
+			continue
+		}
+		if f := formatDecimal128(lo, hi); f != s {
+			t.Errorf("%s: %s", s, f)
+		}
+	}
+	// 1 and 1.0 have different representations
+	lo, hi, _ := parseDecimal128("1")
+	if lo != 1 || hi != 0x3040000000000000 {
+		t.Errorf("%x %x", lo, hi)
+	}
+	for _, s := range []string{"", "1.2.3", "x", "1E+7000", "12345678901234567890123456789012345"} {
+		if _, _, err := parseDecimal128(s); err == nil {
+			t.Errorf("%s: expected error", s)
+		}
+	}
+}
+
+func TestFromBSONErrors(t *testing.T) {
+	for _, in := range []string{"", "05000000", "0500000001", "0600000000", "0c0000000261000200000062",
+		"0800000008610002", "0800000014610000", "050000000000"} {
+		data, _ := hex.DecodeString(in)
+		if _, err := FromBSON(data, ExtJSONOptions{}); err == nil {
+			t.Errorf("%s: expected error", in)
+		}
+	}
+}
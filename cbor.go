@@ -0,0 +1,459 @@
+package jnode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// CBOR major types (RFC 8949.)
+const (
+	cborUint   = 0
+	cborNegInt = 1
+	cborBytes  = 2
+	cborText   = 3
+	cborArray  = 4
+	cborMap    = 5
+	cborTag    = 6
+	cborSimple = 7
+)
+
+// maxCBORDepth limits the nesting of decoded CBOR items.
+const maxCBORDepth = 1000
+
+// MarshalCBOR encodes a Node as CBOR (RFC 8949.)  Binary Nodes become
+// byte strings, integers are encoded as integers (in their shortest
+// form) and floats as floats of the same size, so the types survive a
+// round trip through FromCBOR.  Object fields are written in sorted
+// order.  A missing Node is encoded as undefined.
+func (n *Node) MarshalCBOR() ([]byte, error) {
+	e := &cborEncoder{}
+	if err := e.encode(n.value, n == MissingNode); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+// MarshalCBORCanonical encodes a Node as deterministically encoded CBOR
+// (RFC 8949 section 4.2.1): in addition to what MarshalCBOR does, floats
+// are written in the shortest form that preserves their value, and object
+// fields are sorted by the bytewise order of their encoded names.
+func (n *Node) MarshalCBORCanonical() ([]byte, error) {
+	e := &cborEncoder{canonical: true}
+	if err := e.encode(n.value, n == MissingNode); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+type cborEncoder struct {
+	buf       bytes.Buffer
+	canonical bool
+}
+
+func (e *cborEncoder) head(major byte, arg uint64) {
+	m := major << 5
+	switch {
+	case arg < 24:
+		e.buf.WriteByte(m | byte(arg))
+	case arg <= math.MaxUint8:
+		e.buf.Write([]byte{m | 24, byte(arg)})
+	case arg <= math.MaxUint16:
+		e.buf.WriteByte(m | 25)
+		e.buf.Write(binary.BigEndian.AppendUint16(nil, uint16(arg)))
+	case arg <= math.MaxUint32:
+		e.buf.WriteByte(m | 26)
+		e.buf.Write(binary.BigEndian.AppendUint32(nil, uint32(arg)))
+	default:
+		e.buf.WriteByte(m | 27)
+		e.buf.Write(binary.BigEndian.AppendUint64(nil, arg))
+	}
+}
+
+func (e *cborEncoder) int(i int64) {
+	if i < 0 {
+		e.head(cborNegInt, uint64(-(i + 1)))
+	} else {
+		e.head(cborUint, uint64(i))
+	}
+}
+
+func (e *cborEncoder) float(f float64, bits int) {
+	if e.canonical {
+		if float64(float32(f)) == f || math.IsNaN(f) {
+			if h, ok := float16Bits(float32(f)); ok {
+				e.buf.WriteByte(cborSimple<<5 | 25)
+				e.buf.Write(binary.BigEndian.AppendUint16(nil, h))
+				return
+			}
+			bits = 32
+		} else {
+			bits = 64
+		}
+	}
+	if bits == 32 {
+		e.buf.WriteByte(cborSimple<<5 | 26)
+		e.buf.Write(binary.BigEndian.AppendUint32(nil, math.Float32bits(float32(f))))
+	} else {
+		e.buf.WriteByte(cborSimple<<5 | 27)
+		e.buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(f)))
+	}
+}
+
+func (e *cborEncoder) encode(value interface{}, missing bool) error {
+	switch v := value.(type) {
+	case nil:
+		e.buf.WriteByte(0xf6)
+	case bool:
+		if v {
+			e.buf.WriteByte(0xf5)
+		} else {
+			e.buf.WriteByte(0xf4)
+		}
+	case string:
+		if missing {
+			e.buf.WriteByte(0xf7)
+			break
+		}
+		e.head(cborText, uint64(len(v)))
+		e.buf.WriteString(v)
+	case []byte:
+		e.head(cborBytes, uint64(len(v)))
+		e.buf.Write(v)
+	case int:
+		e.int(int64(v))
+	case int8:
+		e.int(int64(v))
+	case int16:
+		e.int(int64(v))
+	case int32:
+		e.int(int64(v))
+	case int64:
+		e.int(v)
+	case uint:
+		e.head(cborUint, uint64(v))
+	case uint8:
+		e.head(cborUint, uint64(v))
+	case uint16:
+		e.head(cborUint, uint64(v))
+	case uint32:
+		e.head(cborUint, uint64(v))
+	case uint64:
+		e.head(cborUint, v)
+	case float32:
+		e.float(float64(v), 32)
+	case float64:
+		e.float(v, 64)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			e.int(i)
+		} else if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			e.head(cborUint, u)
+		} else if f, err := v.Float64(); err == nil {
+			e.float(f, 64)
+		} else {
+			return fmt.Errorf("invalid number %s", v)
+		}
+	case *[]interface{}:
+		e.head(cborArray, uint64(len(*v)))
+		for _, x := range *v {
+			if err := e.encode(x, false); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		keys := sortedKeys(v)
+		if e.canonical {
+			// shorter names have shorter encodings, and names of the
+			// same length have the same head
+			sort.SliceStable(keys, func(i, j int) bool {
+				return len(keys[i]) < len(keys[j])
+			})
+		}
+		e.head(cborMap, uint64(len(v)))
+		for _, k := range keys {
+			e.head(cborText, uint64(len(k)))
+			e.buf.WriteString(k)
+			if err := e.encode(v[k], false); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%T cannot be encoded as CBOR", value)
+	}
+	return nil
+}
+
+// FromCBOR creates a Node from a CBOR data item.  Byte strings become
+// Binary Nodes, unsigned and negative integers become int64 (or uint64
+// for unsigned integers too large for an int64), half and double floats
+// become float64 and single floats become float32.  Indefinite length
+// strings, arrays and maps are accepted.  Map keys must be text strings
+// or integers (which are converted to text.)  Tags are ignored (the
+// tagged item is decoded), and undefined is decoded as null.
+func FromCBOR(data []byte) (*Node, error) {
+	d := &cborDecoder{data: data}
+	v, err := d.decode(0)
+	if err != nil {
+		return MissingNode, err
+	}
+	if d.pos != len(data) {
+		return MissingNode, fmt.Errorf("cbor: %d bytes of extra data after item", len(data)-d.pos)
+	}
+	return &Node{v}, nil
+}
+
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+// cborBreak is returned by item when it reads the break stop code.
+var cborBreak = &struct{}{}
+
+func (d *cborDecoder) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("cbor: %s at offset %d", fmt.Sprintf(format, args...), d.pos)
+}
+
+func (d *cborDecoder) read(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, d.errorf("unexpected end of data")
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+// head reads the initial byte and argument of an item.  For
+// indefinite lengths (additional info 31) indefinite is true.
+func (d *cborDecoder) head() (major, info byte, arg uint64, indefinite bool, err error) {
+	b, err := d.read(1)
+	if err != nil {
+		return
+	}
+	major, info = b[0]>>5, b[0]&0x1f
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info <= 27:
+		var a []byte
+		if a, err = d.read(1 << (info - 24)); err != nil {
+			return
+		}
+		for _, x := range a {
+			arg = arg<<8 | uint64(x)
+		}
+	case info == 31:
+		indefinite = true
+	default:
+		err = d.errorf("invalid additional information %d", info)
+	}
+	return
+}
+
+func (d *cborDecoder) decode(depth int) (interface{}, error) {
+	v, err := d.item(depth)
+	if err == nil && v == cborBreak {
+		err = d.errorf("unexpected break")
+	}
+	return v, err
+}
+
+func (d *cborDecoder) item(depth int) (interface{}, error) {
+	if depth > maxCBORDepth {
+		return nil, d.errorf("data is nested too deeply")
+	}
+	major, info, arg, indefinite, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	if indefinite && (major == cborUint || major == cborNegInt || major == cborTag) {
+		return nil, d.errorf("invalid indefinite length")
+	}
+	switch major {
+	case cborUint:
+		if arg > math.MaxInt64 {
+			return arg, nil
+		}
+		return int64(arg), nil
+	case cborNegInt:
+		if arg > math.MaxInt64 {
+			return nil, d.errorf("negative integer is out of range")
+		}
+		return -1 - int64(arg), nil
+	case cborBytes, cborText:
+		b, err := d.str(major, arg, indefinite)
+		if err != nil {
+			return nil, err
+		}
+		if major == cborBytes {
+			return b, nil
+		}
+		if !utf8.Valid(b) {
+			return nil, d.errorf("invalid UTF-8 in text string")
+		}
+		return string(b), nil
+	case cborArray:
+		a := make([]interface{}, 0)
+		for i := uint64(0); indefinite || i < arg; i++ {
+			v, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if v == cborBreak {
+				if !indefinite {
+					return nil, d.errorf("unexpected break")
+				}
+				break
+			}
+			a = append(a, v)
+		}
+		return &a, nil
+	case cborMap:
+		m := make(map[string]interface{})
+		for i := uint64(0); indefinite || i < arg; i++ {
+			k, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if k == cborBreak {
+				if !indefinite {
+					return nil, d.errorf("unexpected break")
+				}
+				break
+			}
+			var key string
+			switch k := k.(type) {
+			case string:
+				key = k
+			case int64:
+				key = strconv.FormatInt(k, 10)
+			case uint64:
+				key = strconv.FormatUint(k, 10)
+			default:
+				return nil, d.errorf("unsupported map key type %T", k)
+			}
+			v, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+		}
+		return m, nil
+	case cborTag:
+		return d.decode(depth + 1)
+	default:
+		return d.simple(info, arg, indefinite)
+	}
+}
+
+// str reads the contents of a byte or text string.
+func (d *cborDecoder) str(major byte, arg uint64, indefinite bool) ([]byte, error) {
+	if !indefinite {
+		b, err := d.read(arg)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{}, b...), nil
+	}
+	var buf []byte
+	for {
+		m, info, n, ind, err := d.head()
+		if err != nil {
+			return nil, err
+		}
+		if m == cborSimple && info == 31 {
+			return buf, nil
+		}
+		if m != major || ind {
+			return nil, d.errorf("invalid chunk in indefinite length string")
+		}
+		b, err := d.read(n)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, b...)
+	}
+}
+
+func (d *cborDecoder) simple(info byte, arg uint64, indefinite bool) (interface{}, error) {
+	switch {
+	case indefinite:
+		return cborBreak, nil
+	case info == 20:
+		return false, nil
+	case info == 21:
+		return true, nil
+	case info == 22, info == 23:
+		return nil, nil
+	case info == 25:
+		return float64(float16Value(uint16(arg))), nil
+	case info == 26:
+		return math.Float32frombits(uint32(arg)), nil
+	case info == 27:
+		return math.Float64frombits(arg), nil
+	default:
+		return nil, d.errorf("unsupported simple value %d", arg)
+	}
+}
+
+// float16Bits returns the IEEE 754 half precision encoding of f, if
+// f can be represented exactly.  NaNs are encoded as the canonical
+// quiet NaN.
+func float16Bits(f float32) (uint16, bool) {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int(bits>>23) & 0xff
+	mant := bits & 0x7fffff
+	switch {
+	case exp == 0xff && mant != 0:
+		return 0x7e00, true
+	case exp == 0xff:
+		return sign | 0x7c00, true
+	case exp == 0 && mant == 0:
+		return sign, true
+	case exp == 0:
+		return 0, false
+	}
+	e := exp - 127
+	switch {
+	case e >= -14 && e <= 15:
+		if mant&0x1fff != 0 {
+			return 0, false
+		}
+		return sign | uint16(e+15)<<10 | uint16(mant>>13), true
+	case e >= -24 && e < -14:
+		full := mant | 1<<23
+		shift := uint(-e - 1)
+		if full&(1<<shift-1) != 0 {
+			return 0, false
+		}
+		return sign | uint16(full>>shift), true
+	default:
+		return 0, false
+	}
+}
+
+// float16Value decodes an IEEE 754 half precision float.
+func float16Value(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h & 0x3ff)
+	switch exp {
+	case 0:
+		f := float32(mant) / (1 << 24)
+		if sign != 0 {
+			f = -f
+		}
+		return f
+	case 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	default:
+		return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+	}
+}
@@ -0,0 +1,122 @@
+package jnode
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"testing"
+)
+
+func TestCBORRoundTrip(t *testing.T) {
+	n := NewObjectNode().Put("b", []byte{0, 1, 2}).Put("i", 10).Put("f", 1.5).
+		Put("u", uint64(math.MaxUint64)).Put("neg", -500).Put("s", "hi").
+		Put("t", true).Put("z", nil)
+	n.PutArray("a").Append(1).Append("x")
+	data, err := n.MarshalCBOR()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := FromCBOR(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Path("b").GetType() != Binary {
+		t.Error(m.Path("b").GetType())
+	}
+	if b, _ := m.Path("b").AsBinary(); !bytes.Equal(b, []byte{0, 1, 2}) {
+		t.Error(b)
+	}
+	if v, ok := m.Path("i").Unwrap().(int64); !ok || v != 10 {
+		t.Error(m.Path("i").Unwrap())
+	}
+	if v, ok := m.Path("f").Unwrap().(float64); !ok || v != 1.5 {
+		t.Error(m.Path("f").Unwrap())
+	}
+	if v, ok := m.Path("u").Unwrap().(uint64); !ok || v != math.MaxUint64 {
+		t.Error(m.Path("u").Unwrap())
+	}
+	if m.Path("neg").AsInt() != -500 || m.Path("s").AsText() != "hi" ||
+		!m.Path("t").AsBool() || !m.Path("z").IsNull() || m.Path("a").Size() != 2 {
+		t.Error(m)
+	}
+}
+
+func TestCBORCanonical(t *testing.T) {
+	n := NewObjectNode().Put("bb", 1.5).Put("a", 100000.0).Put("c", 1.1).Put("d", float32(0.5))
+	data, err := n.MarshalCBORCanonical()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// keys a, c, bb; 100000.0 as single, 1.1 as double, 1.5 and 0.5 as half
+	if s := hex.EncodeToString(data); s != "a46161fa47c350006163fb3ff199999999999a6164f93800626262f93e00" {
+		t.Error(s)
+	}
+	again, _ := n.MarshalCBORCanonical()
+	if !bytes.Equal(data, again) {
+		t.Error("not deterministic")
+	}
+	plain, _ := n.MarshalCBOR()
+	if bytes.Equal(data, plain) {
+		t.Error("canonical and default encodings should differ")
+	}
+}
+
+func TestCBORFloat16(t *testing.T) {
+	tests := map[float64]string{
+		0: "f90000", math.Copysign(0, -1): "f98000", 1: "f93c00", 65504: "f97bff",
+		5.960464477539063e-8: "f90001", 0.00006103515625: "f90400", -4: "f9c400",
+		math.Inf(1): "f97c00", math.NaN(): "f97e00", 1e300: "fb7e37e43c8800759c",
+	}
+	for f, want := range tests {
+		data, _ := NewNode(f).MarshalCBORCanonical()
+		if s := hex.EncodeToString(data); s != want {
+			t.Errorf("%v: %s", f, s)
+		}
+		n, err := FromCBOR(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g := n.AsFloat(); g != f && !(math.IsNaN(f) && math.IsNaN(g)) {
+			t.Errorf("%v: decoded %v", f, g)
+		}
+	}
+}
+
+func TestFromCBOR(t *testing.T) {
+	// examples from RFC 8949 appendix A
+	tests := map[string]string{
+		"1bffffffffffffffff":         `18446744073709551615`,
+		"3903e7":                     `-1000`,
+		"5f42010243030405ff":         `"AQIDBAU="`,
+		"7f657374726561646d696e67ff": `"streaming"`,
+		"9f018202039f0405ffff":       `[1,[2,3],[4,5]]`,
+		"bf61610161629f0203ffff":     `{"a":1,"b":[2,3]}`,
+		"a201020304":                 `{"1":2,"3":4}`,
+		"c06a323031332d30332d3231":   `"2013-03-21"`,
+		"f7":                         `null`,
+		"fa47c35000":                 `100000`,
+	}
+	for in, want := range tests {
+		data, _ := hex.DecodeString(in)
+		n, err := FromCBOR(data)
+		if err != nil {
+			t.Errorf("%s: %v", in, err)
+			continue
+		}
+		if s := n.String(); s != want {
+			t.Errorf("%s: %s", in, s)
+		}
+	}
+}
+
+func TestFromCBORErrors(t *testing.T) {
+	for _, in := range []string{"", "18", "62", "ff", "8201", "a1f401", "5f6161ff", "62c328", "0000", "fc", "3bffffffffffffffff"} {
+		data, _ := hex.DecodeString(in)
+		if _, err := FromCBOR(data); err == nil {
+			t.Errorf("%s: expected error", in)
+		}
+	}
+	if _, err := FromCBOR(bytes.Repeat([]byte{0x81}, 2000)); err == nil {
+		t.Error("expected depth error")
+	}
+}
@@ -0,0 +1,221 @@
+package jnode
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// ConfigSource supplies one layer of configuration to a Config.
+type ConfigSource interface {
+	// Name identifies the source in explanations and errors.
+	Name() string
+	// Load returns the configuration as an object Node.
+	Load() (*Node, error)
+}
+
+type configFunc struct {
+	name string
+	load func() (*Node, error)
+}
+
+func (s *configFunc) Name() string         { return s.name }
+func (s *configFunc) Load() (*Node, error) { return s.load() }
+
+// ConfigFunc creates a ConfigSource from a function, e.g. to read a
+// file format other than JSON.
+func ConfigFunc(name string, load func() (*Node, error)) ConfigSource {
+	return &configFunc{name, load}
+}
+
+// ConfigNode creates a ConfigSource from a fixed object Node, which
+// is typically used for defaults.  The Node is copied on each load.
+func ConfigNode(name string, n *Node) ConfigSource {
+	return ConfigFunc(name, func() (*Node, error) {
+		return &Node{copyValue(n.value)}, nil
+	})
+}
+
+// ConfigFile creates a ConfigSource that reads a JSON file.  If optional
+// is true then a missing file is treated as an empty object.
+func ConfigFile(path string, optional bool) ConfigSource {
+	return ConfigFunc(path, func() (*Node, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if optional && os.IsNotExist(err) {
+				return NewObjectNode(), nil
+			}
+			return nil, err
+		}
+		return FromJSON(data)
+	})
+}
+
+// ConfigEnv creates a ConfigSource from the process environment
+// (see FromEnv.)
+func ConfigEnv(opts EnvOptions) ConfigSource {
+	return ConfigFunc("env", func() (*Node, error) {
+		return FromEnv(os.Environ(), opts)
+	})
+}
+
+// ConfigFlags creates a ConfigSource from a list of flat string
+// assignments (see FromFlags.)
+func ConfigFlags(assignments []string, typed bool) ConfigSource {
+	return ConfigFunc("flags", func() (*Node, error) {
+		return FromFlags(assignments, typed)
+	})
+}
+
+// Config loads configuration from a list of sources into a single
+// object Node.  Sources are applied in order, so later sources
+// override earlier ones.  Objects are merged field by field, while
+// any other value (including an array) from a later source replaces
+// the earlier value.  A typical order is defaults, a configuration
+// file, environment variables and then command line flags:
+//
+//     c := jnode.NewConfig(
+//         jnode.ConfigNode("defaults", defaults),
+//         jnode.ConfigFile("config.json", true),
+//         jnode.ConfigEnv(jnode.EnvOptions{Prefix: "APP_"}),
+//         jnode.ConfigFlags(setFlags, true))
+//     if err := c.Load(); err != nil {
+//         ...
+//     }
+//     port := c.Node().Path("server").Path("port").AsInt()
+//
+// Config records which source supplied each leaf value (see Origin
+// and Explain.)  A Config is safe for concurrent use.
+type Config struct {
+	sources   []ConfigSource
+	mu        sync.RWMutex
+	node      *Node
+	text      string
+	origins   map[string]string
+	listeners []func(old, new *Node)
+}
+
+// NewConfig creates a Config from a list of sources in increasing
+// order of priority.  Call Load to load the configuration.
+func NewConfig(sources ...ConfigSource) *Config {
+	return &Config{sources: sources, node: NewObjectNode(), text: "{}"}
+}
+
+// Load loads (or reloads) the configuration from all sources.
+func (c *Config) Load() error {
+	_, err := c.Reload()
+	return err
+}
+
+// Reload loads the configuration from all sources, and returns true
+// if the configuration changed.  If the configuration had been loaded
+// previously and has changed, the functions registered with OnChange
+// are called.  If any source fails the current configuration is kept.
+func (c *Config) Reload() (bool, error) {
+	node := NewObjectNode()
+	origins := make(map[string]string)
+	for _, src := range c.sources {
+		n, err := src.Load()
+		if err != nil {
+			return false, fmt.Errorf("config source %s: %w", src.Name(), err)
+		}
+		if !n.IsObject() {
+			return false, fmt.Errorf("config source %s is not an object", src.Name())
+		}
+		overlay(node, n, "", src.Name(), origins)
+	}
+	// serialize the new configuration before it's shared, since
+	// marshalling a Node isn't safe while other goroutines read it
+	text := node.String()
+	c.mu.Lock()
+	old := c.node
+	loaded := c.origins != nil
+	changed := c.text != text
+	c.node = node
+	c.text = text
+	c.origins = origins
+	listeners := c.listeners
+	c.mu.Unlock()
+	if loaded && changed {
+		for _, fn := range listeners {
+			fn(old, node)
+		}
+	}
+	return changed, nil
+}
+
+// OnChange registers a function that is called with the old and
+// new configuration when Reload finds the configuration has changed.
+func (c *Config) OnChange(fn func(old, new *Node)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listeners = append(c.listeners, fn)
+}
+
+// Node returns the current configuration.  The Node must not be
+// modified.
+func (c *Config) Node() *Node {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.node
+}
+
+// Origin returns the name of the source that supplied a leaf value,
+// or "" if there is no such value.  The key is a dotted field name as
+// used by ToFlatStringDotted, e.g. "server.ports[0]".
+func (c *Config) Origin(key string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.origins[key]
+}
+
+// Explain returns the configuration with one line per leaf value
+// in the form "key=value # source", sorted by key.
+func (c *Config) Explain() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var sb strings.Builder
+	_ = flatLeaves("", c.node, true, func(key string, leaf *Node) error {
+		w := &flatWriter{}
+		if err := w.writeValue(leaf); err != nil {
+			w.sb.WriteString(leaf.String())
+		}
+		fmt.Fprintf(&sb, "%s=%s # %s\n", key, w.sb.String(), c.origins[key])
+		return nil
+	})
+	return sb.String()
+}
+
+// overlay applies the fields of src to dst, merging objects, and
+// records the origin of each leaf value that src supplies.  prefix
+// is the dotted field name of dst.
+func overlay(dst, src *Node, prefix, name string, origins map[string]string) {
+	dm := dst.ToMap()
+	for k, v := range src.ToMap() {
+		var sb strings.Builder
+		sb.WriteString(prefix)
+		if prefix != "" {
+			sb.WriteByte('.')
+		}
+		writeFlatField(&sb, k)
+		key := sb.String()
+		sv := &Node{v}
+		if dv, ok := dm[k]; ok {
+			if d := (&Node{dv}); d.IsObject() && sv.IsObject() {
+				overlay(d, sv, key, name, origins)
+				continue
+			}
+		}
+		for o := range origins {
+			if o == key || strings.HasPrefix(o, key+".") || strings.HasPrefix(o, key+"[") {
+				delete(origins, o)
+			}
+		}
+		dm[k] = copyValue(v)
+		_ = flatLeaves(key, sv, true, func(leaf string, _ *Node) error {
+			origins[leaf] = name
+			return nil
+		})
+	}
+}
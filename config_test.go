@@ -0,0 +1,118 @@
+package jnode
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestConfig(t *testing.T) {
+	dir, err := os.MkdirTemp("", "jnode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(file, []byte(`{"server":{"port":8080,"hosts":["a","b"]},"debug":true}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("JNODE_TEST_SERVER__HOST", "example.com")
+	defer os.Unsetenv("JNODE_TEST_SERVER__HOST")
+	defaults := NewObjectNode().Put("name", "app").Put("debug", false)
+	defaults.PutObject("server").Put("port", 80).Put("host", "localhost").PutArray("hosts").Append("x")
+	c := NewConfig(
+		ConfigNode("defaults", defaults),
+		ConfigFile(file, false),
+		ConfigFile(filepath.Join(dir, "missing.json"), true),
+		ConfigEnv(EnvOptions{Prefix: "JNODE_TEST_"}),
+		ConfigFlags([]string{"server.port=9090"}, true))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if s := c.Node().String(); s != `{"debug":true,"name":"app","server":{"host":"example.com","hosts":["a","b"],"port":9090}}` {
+		t.Error(s)
+	}
+	if o := c.Origin("server.hosts[1]"); o != file {
+		t.Error(o)
+	}
+	if o := c.Origin("server.port"); o != "flags" {
+		t.Error(o)
+	}
+	expected := strings.Join([]string{
+		"debug=true # " + file,
+		"name=app # defaults",
+		"server.host=example.com # env",
+		"server.hosts[0]=a # " + file,
+		"server.hosts[1]=b # " + file,
+		"server.port=9090 # flags",
+		""}, "\n")
+	if s := c.Explain(); s != expected {
+		t.Error(s)
+	}
+	if defaults.Path("server").Path("port").AsInt() != 80 {
+		t.Error(defaults)
+	}
+}
+
+func TestConfigReload(t *testing.T) {
+	value := NewObjectNode().Put("a", 1)
+	c := NewConfig(ConfigFunc("test", func() (*Node, error) {
+		return &Node{copyValue(value.value)}, nil
+	}))
+	var changes []string
+	c.OnChange(func(old, new *Node) {
+		changes = append(changes, old.String()+" -> "+new.String())
+	})
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := c.Reload(); changed || err != nil {
+		t.Error(changed, err)
+	}
+	value.Put("a", 2)
+	if changed, err := c.Reload(); !changed || err != nil {
+		t.Error(changed, err)
+	}
+	if len(changes) != 1 || changes[0] != `{"a":1} -> {"a":2}` {
+		t.Error(changes)
+	}
+	value = NewNode("bad")
+	if _, err := c.Reload(); err == nil {
+		t.Error("non-object source should fail")
+	}
+	if c.Node().Path("a").AsInt() != 2 {
+		t.Error(c.Node())
+	}
+}
+
+func TestConfigConcurrentReload(t *testing.T) {
+	c := NewConfig(ConfigNode("test", mustJSON(t, `{"a":[1,[2,3]],"b":{"c":["x"]}}`)))
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				_ = c.Node().Path("a").Get(1).Get(0).AsInt()
+			}
+		}()
+	}
+	for j := 0; j < 1000; j++ {
+		if changed, err := c.Reload(); changed || err != nil {
+			t.Error(changed, err)
+		}
+	}
+	close(done)
+	wg.Wait()
+}
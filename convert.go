@@ -0,0 +1,235 @@
+package jnode
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// As converts a Node to a Go value of type T, which may be string,
+// bool, any of the int, uint and float types, []byte, *Node or
+// interface{} (which returns Unwrap().)  The conversions follow AsText,
+// AsInt, AsFloat, AsBool and AsBinary, except that a conversion that
+// would lose information returns an error instead: a null, missing or
+// container Node (unless T is *Node or interface{}), text that doesn't
+// parse as the requested type, a number with a fractional part
+// converted to an integer type, or a number out of range.
+func As[T any](n *Node) (T, error) {
+	var v T
+	err := convertNode(n, &v)
+	return v, err
+}
+
+// SliceOf converts an Array Node to a slice, converting each
+// element with As.
+func SliceOf[T any](n *Node) ([]T, error) {
+	if !n.IsArray() {
+		return nil, fmt.Errorf("%s node is not an array", n.GetType())
+	}
+	s := make([]T, 0, n.Size())
+	for i, e := range n.Items() {
+		v, err := As[T](e)
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+		s = append(s, v)
+	}
+	return s, nil
+}
+
+// MapOf converts an Object Node to a map, converting each field
+// value with As.
+func MapOf[T any](n *Node) (map[string]T, error) {
+	if !n.IsObject() {
+		return nil, fmt.Errorf("%s node is not an object", n.GetType())
+	}
+	m := make(map[string]T, n.Size())
+	for k, e := range n.Fields() {
+		v, err := As[T](e)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", k, err)
+		}
+		m[k] = v
+	}
+	return m, nil
+}
+
+// PathOr returns the value at path within n converted with As, or
+// def if there is no such value or it can't be converted.
+func PathOr[T any](n *Node, path Path, def T) T {
+	v, err := As[T](path.Find(n))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+func convertNode(n *Node, target interface{}) error {
+	switch p := target.(type) {
+	case **Node:
+		*p = n
+		return nil
+	case *interface{}:
+		*p = n.Unwrap()
+		return nil
+	}
+	t := n.GetType()
+	switch t {
+	case Null, Missing, Object, Array, Unknown:
+		return fmt.Errorf("cannot convert %s node to %s", t, targetName(target))
+	}
+	switch p := target.(type) {
+	case *string:
+		if t == Binary {
+			return fmt.Errorf("cannot convert %s node to string", t)
+		}
+		*p = n.AsText()
+	case *bool:
+		switch t {
+		case Text:
+			b, err := strconv.ParseBool(strings.ToLower(n.AsText()))
+			if err != nil {
+				return fmt.Errorf("cannot convert %q to bool", n.AsText())
+			}
+			*p = b
+		case Binary:
+			return fmt.Errorf("cannot convert %s node to bool", t)
+		default:
+			*p = n.AsBool()
+		}
+	case *float64:
+		return convertFloat(n, t, p, 64)
+	case *float32:
+		var f float64
+		if err := convertFloat(n, t, &f, 32); err != nil {
+			return err
+		}
+		if math.Abs(f) > math.MaxFloat32 && !math.IsInf(f, 0) {
+			return fmt.Errorf("%s is out of range for float32", n)
+		}
+		*p = float32(f)
+	case *[]byte:
+		b, err := n.AsBinary()
+		if err != nil {
+			return err
+		}
+		*p = b
+	case *int:
+		return convertInt(n, t, p, strconv.IntSize)
+	case *int8:
+		return convertInt(n, t, p, 8)
+	case *int16:
+		return convertInt(n, t, p, 16)
+	case *int32:
+		return convertInt(n, t, p, 32)
+	case *int64:
+		return convertInt(n, t, p, 64)
+	case *uint:
+		return convertUint(n, t, p, strconv.IntSize)
+	case *uint8:
+		return convertUint(n, t, p, 8)
+	case *uint16:
+		return convertUint(n, t, p, 16)
+	case *uint32:
+		return convertUint(n, t, p, 32)
+	case *uint64:
+		return convertUint(n, t, p, 64)
+	default:
+		return fmt.Errorf("cannot convert to %s", targetName(target))
+	}
+	return nil
+}
+
+func targetName(target interface{}) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", target), "*")
+}
+
+func convertFloat(n *Node, t NodeType, p *float64, bits int) error {
+	switch t {
+	case Text:
+		f, err := strconv.ParseFloat(n.AsText(), bits)
+		if err != nil {
+			return fmt.Errorf("cannot convert %q to float%d", n.AsText(), bits)
+		}
+		*p = f
+	case Binary:
+		return fmt.Errorf("cannot convert %s node to float%d", t, bits)
+	default:
+		*p = n.AsFloat()
+	}
+	return nil
+}
+
+// integerValue returns the value of a Bool, Number or Text Node as an
+// int64 or uint64 (for values too large for int64.)
+func integerValue(n *Node, t NodeType) (int64, uint64, error) {
+	switch v := n.value.(type) {
+	case uint:
+		return integerValue(&Node{uint64(v)}, t)
+	case uint64:
+		if v <= math.MaxInt64 {
+			return int64(v), 0, nil
+		}
+		return 0, v, nil
+	case json.Number:
+		return integerValue(&Node{string(v)}, Text)
+	}
+	switch t {
+	case Text:
+		s := n.AsText()
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, 0, nil
+		}
+		if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return 0, u, nil
+		}
+		return 0, 0, fmt.Errorf("cannot convert %q to an integer", s)
+	case Number:
+		switch n.value.(type) {
+		case float32, float64:
+			f := n.AsFloat()
+			if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+				return 0, 0, fmt.Errorf("cannot convert %v to an integer", f)
+			}
+			return int64(f), 0, nil
+		}
+		return int64(n.AsInt()), 0, nil
+	case Bool:
+		return int64(n.AsInt()), 0, nil
+	default:
+		return 0, 0, fmt.Errorf("cannot convert %s node to an integer", t)
+	}
+}
+
+func convertInt[I int | int8 | int16 | int32 | int64](n *Node, t NodeType, p *I, bits int) error {
+	i, u, err := integerValue(n, t)
+	if err != nil {
+		return err
+	}
+	min := int64(-1) << (bits - 1)
+	if u != 0 || i < min || i > -(min+1) {
+		return fmt.Errorf("%s is out of range for int%d", n, bits)
+	}
+	*p = I(i)
+	return nil
+}
+
+func convertUint[U uint | uint8 | uint16 | uint32 | uint64](n *Node, t NodeType, p *U, bits int) error {
+	i, u, err := integerValue(n, t)
+	if err != nil {
+		return err
+	}
+	if u == 0 {
+		if i < 0 {
+			return fmt.Errorf("%s is out of range for uint%d", n, bits)
+		}
+		u = uint64(i)
+	}
+	if bits < 64 && u > 1<<bits-1 {
+		return fmt.Errorf("%s is out of range for uint%d", n, bits)
+	}
+	*p = U(u)
+	return nil
+}
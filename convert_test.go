@@ -0,0 +1,173 @@
+package jnode
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestAs(t *testing.T) {
+	if v, err := As[int](NewNode("42")); err != nil || v != 42 {
+		t.Error(v, err)
+	}
+	if v, err := As[int](NewNode(42.0)); err != nil || v != 42 {
+		t.Error(v, err)
+	}
+	if v, err := As[int64](NewNode(true)); err != nil || v != 1 {
+		t.Error(v, err)
+	}
+	if v, err := As[uint64](NewNode(uint64(18446744073709551615))); err != nil || v != 18446744073709551615 {
+		t.Error(v, err)
+	}
+	if v, err := As[uint64](NewNode("18446744073709551615")); err != nil || v != 18446744073709551615 {
+		t.Error(v, err)
+	}
+	if v, err := As[int](&Node{json.Number("123")}); err != nil || v != 123 {
+		t.Error(v, err)
+	}
+	if v, err := As[float64](NewNode("1.5")); err != nil || v != 1.5 {
+		t.Error(v, err)
+	}
+	if v, err := As[float32](NewNode(2)); err != nil || v != 2 {
+		t.Error(v, err)
+	}
+	if v, err := As[bool](NewNode("TRUE")); err != nil || !v {
+		t.Error(v, err)
+	}
+	if v, err := As[bool](NewNode(0)); err != nil || v {
+		t.Error(v, err)
+	}
+	if v, err := As[string](NewNode(10)); err != nil || v != "10" {
+		t.Error(v, err)
+	}
+	if v, err := As[[]byte](NewNode([]byte("hi"))); err != nil || !bytes.Equal(v, []byte("hi")) {
+		t.Error(v, err)
+	}
+	o := NewObjectNode().Put("a", 1)
+	if v, err := As[*Node](o); err != nil || v != o {
+		t.Error(v, err)
+	}
+	if v, err := As[interface{}](o); err != nil || v.(map[string]interface{})["a"] != 1 {
+		t.Error(v, err)
+	}
+}
+
+func TestAsErrors(t *testing.T) {
+	check := func(err error) {
+		t.Helper()
+		if err == nil {
+			t.Error("expected error")
+		}
+	}
+	_, err := As[int](NewNode("abc"))
+	check(err)
+	_, err = As[int](NewNode(1.5))
+	check(err)
+	_, err = As[int8](NewNode(128))
+	check(err)
+	_, err = As[int8](NewNode(-129))
+	check(err)
+	_, err = As[uint](NewNode(-1))
+	check(err)
+	_, err = As[uint8](NewNode(256))
+	check(err)
+	_, err = As[int64](NewNode(uint64(1 << 63)))
+	check(err)
+	_, err = As[string](NewObjectNode())
+	check(err)
+	_, err = As[string](NullNode)
+	check(err)
+	_, err = As[bool](NewNode("yes please"))
+	check(err)
+	_, err = As[float64](MissingNode)
+	check(err)
+	_, err = As[[]string](NewNode("x"))
+	check(err)
+	_, err = As[float32](NewNode(1e39))
+	check(err)
+	_, err = As[float32](NewNode(-1e39))
+	check(err)
+	_, err = As[float32](&Node{json.Number("1e39")})
+	check(err)
+	_, err = As[float32](NewNode("1e39"))
+	check(err)
+	if v, err := As[float32](NewNode(math.Inf(-1))); err != nil || !math.IsInf(float64(v), -1) {
+		t.Error(v, err)
+	}
+	if v, err := As[float32](NewNode(math.MaxFloat32)); err != nil || v != math.MaxFloat32 {
+		t.Error(v, err)
+	}
+	for _, src := range []*Node{NewNode(uint(5)), NewNode(uint64(5))} {
+		if v, err := As[int](src); err != nil || v != 5 {
+			t.Error(v, err)
+		}
+		if v, err := As[int8](src); err != nil || v != 5 {
+			t.Error(v, err)
+		}
+		if v, err := As[int16](src); err != nil || v != 5 {
+			t.Error(v, err)
+		}
+		if v, err := As[int32](src); err != nil || v != 5 {
+			t.Error(v, err)
+		}
+		if v, err := As[int64](src); err != nil || v != 5 {
+			t.Error(v, err)
+		}
+	}
+	if v, err := As[int64](NewNode(uint64(math.MaxInt64))); err != nil || v != math.MaxInt64 {
+		t.Error(v, err)
+	}
+	_, err = As[int8](NewNode(uint64(200)))
+	check(err)
+	_, err = As[int64](NewNode(uint(math.MaxInt64 + 1)))
+	check(err)
+	if v, err := SliceOf[int](mustJSON(t, "[]").Append(uint64(1)).Append(uint64(2))); err != nil || len(v) != 2 || v[1] != 2 {
+		t.Error(v, err)
+	}
+	if v, err := As[int8](NewNode(-128)); err != nil || v != -128 {
+		t.Error(v, err)
+	}
+}
+
+func TestSliceOf(t *testing.T) {
+	s, err := SliceOf[string](FromSlice([]interface{}{"a", 1, true}))
+	if err != nil || len(s) != 3 || s[1] != "1" || s[2] != "true" {
+		t.Error(s, err)
+	}
+	if _, err := SliceOf[int](FromSlice([]interface{}{1, "x"})); err == nil || err.Error() != `element 1: cannot convert "x" to an integer` {
+		t.Error(err)
+	}
+	if _, err := SliceOf[int](NewObjectNode()); err == nil {
+		t.Error("object is not a slice")
+	}
+}
+
+func TestMapOf(t *testing.T) {
+	m, err := MapOf[float64](mustJSON(t, `{"a":1,"b":"2.5"}`))
+	if err != nil || len(m) != 2 || m["b"] != 2.5 {
+		t.Error(m, err)
+	}
+	if _, err := MapOf[int](mustJSON(t, `{"a":[]}`)); err == nil {
+		t.Error("array is not an int")
+	}
+	if _, err := MapOf[int](NewArrayNode()); err == nil {
+		t.Error("array is not a map")
+	}
+}
+
+func TestPathOr(t *testing.T) {
+	n := mustJSON(t, `{"server":{"port":"8080","hosts":["a","b"]}}`)
+	if p := PathOr(n, Path{"server", "port"}, 80); p != 8080 {
+		t.Error(p)
+	}
+	if p := PathOr(n, Path{"server", "timeout"}, 30); p != 30 {
+		t.Error(p)
+	}
+	if h := PathOr(n, Path{"server", "hosts", 1}, "x"); h != "b" {
+		t.Error(h)
+	}
+	if h := PathOr(n, Path{"server", "hosts"}, 0); h != 0 {
+		t.Error(h)
+	}
+}
@@ -0,0 +1,156 @@
+package jnode
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// CSVOptions control the conversion between Arrays of Objects and
+// CSV (or TSV) data.
+type CSVOptions struct {
+	// Comma is the field delimiter, ',' by default.  Use '\t' for TSV.
+	Comma rune
+	// Headers sets the columns and their order.  When writing, the
+	// default is the union of the columns of all the rows, in the order
+	// they first appear.  When reading, setting Headers means the data
+	// has no header row.
+	Headers []string
+	// ArraysAsJSON writes Arrays as JSON in a single column, instead
+	// of a column per element (e.g. "tags[0]", "tags[1]".)
+	ArraysAsJSON bool
+	// Nested treats the column names as flat string field names when
+	// reading, so "a.b" and "c[0]" become nested Objects and Arrays.
+	Nested bool
+	// Typed infers the types of values when reading (see
+	// FromFlatStringTyped), and converts JSON Arrays and Objects.  Empty
+	// values become null.
+	Typed bool
+	// OmitEmpty leaves out fields whose value is empty when reading.
+	OmitEmpty bool
+}
+
+func (opts CSVOptions) comma() rune {
+	if opts.Comma == 0 {
+		return ','
+	}
+	return opts.Comma
+}
+
+// ToCSV writes an Array of Objects as CSV, one row per Object.  Nested
+// Objects (and Arrays, unless opts.ArraysAsJSON is set) are flattened
+// into columns named with dotted field names in the flat string format,
+// e.g. "address.city" and "tags[0]".  Null and missing values are
+// written as empty values, and empty Objects and Arrays as "{}" and
+// "[]".
+func ToCSV(array *Node, w io.Writer, opts CSVOptions) error {
+	if !array.IsArray() {
+		return fmt.Errorf("csv: not an array")
+	}
+	rows := make([]map[string]string, array.Size())
+	headers := opts.Headers
+	seen := make(map[string]bool)
+	for i, row := range array.Elements() {
+		if !row.IsObject() {
+			return fmt.Errorf("csv: row %d is not an object", i)
+		}
+		rows[i] = make(map[string]string)
+		err := flatLeaves("", row, !opts.ArraysAsJSON, func(key string, leaf *Node) error {
+			rows[i][key] = leaf.AsText()
+			if opts.Headers == nil && !seen[key] {
+				seen[key] = true
+				headers = append(headers, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw := csv.NewWriter(w)
+	cw.Comma = opts.comma()
+	if opts.Headers == nil {
+		if err := cw.Write(headers); err != nil {
+			return err
+		}
+	}
+	record := make([]string, len(headers))
+	for _, row := range rows {
+		for i, h := range headers {
+			record[i] = row[h]
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// FromCSV reads CSV data into an Array of Objects, using the first row
+// as the field names unless opts.Headers is set.  Values are Text unless
+// opts.Typed is set.  All rows must have the same number of values.
+func FromCSV(r io.Reader, opts CSVOptions) (*Node, error) {
+	cr := csv.NewReader(r)
+	cr.Comma = opts.comma()
+	headers := opts.Headers
+	if headers == nil {
+		var err error
+		if headers, err = cr.Read(); err == io.EOF {
+			return NewArrayNode(), nil
+		} else if err != nil {
+			return MissingNode, err
+		}
+	} else {
+		cr.FieldsPerRecord = len(headers)
+	}
+	keys := make([][]interface{}, len(headers))
+	for i, h := range headers {
+		keys[i] = []interface{}{h}
+		if opts.Nested {
+			p := &flatParser{s: h + "="}
+			key, _, err := p.parseKey()
+			if err == nil && !p.eof() {
+				err = p.unexpected("end of column name")
+			}
+			if err != nil {
+				return MissingNode, fmt.Errorf("csv: column %d: %w", i+1, err)
+			}
+			keys[i] = key
+		}
+	}
+	array := NewArrayNode()
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return array, nil
+		}
+		if err != nil {
+			return MissingNode, err
+		}
+		row := array.AppendObject()
+		for i, s := range record {
+			if s == "" && opts.OmitEmpty {
+				continue
+			}
+			putKey(row, keys[i], &Node{csvValue(s, opts.Typed)})
+		}
+	}
+}
+
+func csvValue(s string, typed bool) interface{} {
+	if !typed {
+		return s
+	}
+	if s == "" {
+		return nil
+	}
+	if s[0] == '[' || s[0] == '{' {
+		var v interface{}
+		if json.Unmarshal([]byte(s), &v) == nil {
+			return pointSlices(v)
+		}
+	}
+	return inferValue(s)
+}
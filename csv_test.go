@@ -0,0 +1,85 @@
+package jnode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestToCSV(t *testing.T) {
+	a := mustJSON(t, `[
+		{"name": "a", "address": {"city": "x", "zip": 1}, "tags": ["t1", "t2"]},
+		{"name": "b,c", "extra": null, "tags": [], "address": {"city": "say \"hi\""}},
+		{"name": "d", "score": 1.5, "ok": true}
+	]`)
+	buf := &bytes.Buffer{}
+	if err := ToCSV(a, buf, CSVOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `address.city,address.zip,name,tags[0],tags[1],extra,tags,ok,score
+x,1,a,t1,t2,,,,
+"say ""hi""",,"b,c",,,,[],,
+,,d,,,,,true,1.5
+`
+	if buf.String() != expected {
+		t.Error(buf.String())
+	}
+	buf.Reset()
+	if err := ToCSV(a, buf, CSVOptions{Comma: '\t', ArraysAsJSON: true, Headers: []string{"name", "tags"}}); err != nil {
+		t.Fatal(err)
+	}
+	if s := buf.String(); s != "a\t\"[\"\"t1\"\",\"\"t2\"\"]\"\nb,c\t[]\nd\t\n" {
+		t.Error(s)
+	}
+	if err := ToCSV(mustJSON(t, `[1]`), buf, CSVOptions{}); err == nil {
+		t.Error("expected error for a non-object row")
+	}
+}
+
+func TestFromCSV(t *testing.T) {
+	data := "name,address.city,tags[1],n,j\nx,y,t,1.5,\"[1,{}]\"\nz,,,,\n"
+	a, err := FromCSV(strings.NewReader(data), CSVOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := a.String(); s != `[{"address.city":"y","j":"[1,{}]","n":"1.5","name":"x","tags[1]":"t"},{"address.city":"","j":"","n":"","name":"z","tags[1]":""}]` {
+		t.Error(s)
+	}
+	a, err = FromCSV(strings.NewReader(data), CSVOptions{Nested: true, Typed: true, OmitEmpty: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := a.String(); s != `[{"address":{"city":"y"},"j":[1,{}],"n":1.5,"name":"x","tags":[null,"t"]},{"name":"z"}]` {
+		t.Error(s)
+	}
+	a, err = FromCSV(strings.NewReader("1\ttrue\n"), CSVOptions{Comma: '\t', Headers: []string{"a", "b"}, Typed: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := a.String(); s != `[{"a":1,"b":true}]` {
+		t.Error(s)
+	}
+	if a, err := FromCSV(strings.NewReader(""), CSVOptions{}); err != nil || a.Size() != 0 {
+		t.Error(a, err)
+	}
+	for _, in := range []string{"a,b\n1\n", "a[x]\n1\n"} {
+		if _, err := FromCSV(strings.NewReader(in), CSVOptions{Nested: true}); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	a := mustJSON(t, `[{"a": {"b": 1, "c": [true, "x"]}, "d\\.e": "f"}, {"a": {"b": 2}, "g": {}}]`)
+	buf := &bytes.Buffer{}
+	if err := ToCSV(a, buf, CSVOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := FromCSV(buf, CSVOptions{Nested: true, Typed: true, OmitEmpty: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != a.String() {
+		t.Error(b)
+	}
+}
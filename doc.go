@@ -59,6 +59,13 @@ All elements of an Array Node can be accessed via Elements().
 Both methods return empty maps or slices if the Node is not an Object or Array
 respectively.
 
+To iterate without building a map or slice, use Fields() (or SortedFields())
+and Items(), which return iterators:
+
+	for name, value := range o.Fields() {
+		...
+	}
+
 JSON Marshal
 
 A Node's String() method returns JSON:
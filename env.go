@@ -0,0 +1,182 @@
+package jnode
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EnvOptions controls how environment variables are converted
+// to a Node by FromEnv and MergeEnv.
+type EnvOptions struct {
+	// Prefix selects the variables to use (e.g. "APP_").  The prefix (and
+	// any separator following it) is removed from the variable name before
+	// it is converted to a field name.  A prefix that ends with a letter
+	// or digit must be followed by the separator, so the prefix "APP"
+	// selects APP__X (with the separator "__") but not APPLE.  Variables
+	// whose remaining name is empty or starts with an underscore are
+	// ignored.
+	Prefix string
+	// Separator splits variable names into nested field names.  The
+	// default is "__", so APP_DB__HOST sets the field db.host.
+	Separator string
+	// PreserveCase keeps the case of variable names.  By default
+	// field names are folded to lower case.
+	PreserveCase bool
+	// Typed infers the type of values (see FromFlatStringTyped.)
+	Typed bool
+}
+
+// FromEnv creates an object Node from a list of environment variables in
+// the form returned by os.Environ().  Each variable name is converted to
+// a path of fields according to opts, and all-digit components of the name
+// are treated as array indices, so with the prefix "APP_" the variable
+// APP_SERVERS__0__HOST sets servers[0].host.  A value that starts with
+// '[', '{' or '"' is parsed with the flat string value syntax (see
+// FromFlatString), so "[a,b]" is an array and "{a=1}" an object, and it
+// is an error if it isn't valid (JSON is not accepted, so {"a":1} is an
+// error.)  Any other value is taken literally, so "a,b" is just a string
+// and backslashes are not escapes.
+func FromEnv(environ []string, opts EnvOptions) (*Node, error) {
+	node := NewObjectNode()
+	if err := MergeEnv(node, environ, opts); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// MergeEnv applies environment variables to an existing object Node,
+// in the same way as MergeFlatString (see FromEnv.)  Variables are
+// applied in sorted order so that the result does not depend on the
+// order of environ.
+func MergeEnv(node *Node, environ []string, opts EnvOptions) error {
+	if !node.IsObject() {
+		return fmt.Errorf("not an object")
+	}
+	sep := opts.Separator
+	if sep == "" {
+		sep = "__"
+	}
+	vars := make(map[string]string)
+	fields := make(map[string]string)
+	for _, kv := range environ {
+		eq := strings.IndexByte(kv, '=')
+		if eq < 0 {
+			continue
+		}
+		if field, ok := envField(kv[:eq], opts.Prefix, sep); ok {
+			vars[kv[:eq]] = kv[eq+1:]
+			fields[kv[:eq]] = field
+		}
+	}
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		key, err := envKey(fields[name], sep, opts.PreserveCase)
+		if err != nil {
+			return fmt.Errorf("environment variable %s: %w", name, err)
+		}
+		val, err := envValue(vars[name], opts.Typed)
+		if err != nil {
+			return fmt.Errorf("environment variable %s: %w", name, err)
+		}
+		putKey(node, key, val)
+	}
+	return nil
+}
+
+// envValue parses the value of a variable.  Only arrays, objects and
+// quoted strings use the flat string syntax; any other value is taken
+// literally (apart from type inference.)
+func envValue(s string, typed bool) (interface{}, error) {
+	if s == "" || strings.IndexByte(`[{"`, s[0]) < 0 {
+		if typed {
+			return inferValue(s), nil
+		}
+		return s, nil
+	}
+	p := &flatParser{s: s, typed: typed}
+	val, err := p.parseValue("", "")
+	if err == nil && !p.eof() {
+		err = p.unexpected("end of value")
+	}
+	return val, err
+}
+
+// envField returns the part of a variable name that follows prefix
+// and its separator, or false if the variable is not selected by prefix.
+func envField(name, prefix, sep string) (string, bool) {
+	field, ok := strings.CutPrefix(name, prefix)
+	if !ok {
+		return "", false
+	}
+	if f, ok := strings.CutPrefix(field, sep); ok {
+		field = f
+	} else if prefix != "" && isAlphanumeric(prefix[len(prefix)-1]) {
+		return "", false
+	}
+	if field == "" || field[0] == '_' || strings.HasPrefix(field, sep) {
+		return "", false
+	}
+	return field, true
+}
+
+func isAlphanumeric(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func envKey(name, sep string, preserveCase bool) ([]interface{}, error) {
+	if !preserveCase {
+		name = strings.ToLower(name)
+	}
+	parts := strings.Split(name, sep)
+	key := make([]interface{}, len(parts))
+	for i, part := range parts {
+		if part == "" {
+			return nil, fmt.Errorf("empty field name")
+		}
+		key[i] = part
+		if i > 0 && strings.Trim(part, "0123456789") == "" {
+			idx, err := strconv.Atoi(part)
+			if err != nil || idx > maxFlatIndex {
+				return nil, fmt.Errorf("array index %s is too large", part)
+			}
+			key[i] = idx
+		}
+	}
+	return key, nil
+}
+
+// FromFlags creates an object Node from a list of assignments in the
+// flat string format, typically collected from repeated command line
+// flags like "--set spec.replicas=3".  Later assignments override earlier
+// ones.  If typed is true the types of values are inferred (see
+// FromFlatStringTyped.)
+func FromFlags(assignments []string, typed bool) (*Node, error) {
+	node := NewObjectNode()
+	if err := MergeFlags(node, assignments, typed); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// MergeFlags applies a list of flat string assignments to an existing
+// object Node (see FromFlags.)
+func MergeFlags(node *Node, assignments []string, typed bool) error {
+	for _, a := range assignments {
+		var err error
+		if typed {
+			err = MergeFlatStringTyped(node, a)
+		} else {
+			err = MergeFlatString(node, a)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid assignment %q: %w", a, err)
+		}
+	}
+	return nil
+}
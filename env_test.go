@@ -0,0 +1,84 @@
+package jnode
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFromEnv(t *testing.T) {
+	environ := []string{
+		"PATH=/bin",
+		"APP_DB__HOST=localhost",
+		"APP_DB__PORT=5432",
+		"APP_DB=ignored",
+		"APP_SERVERS__1__NAME=b",
+		"APP_SERVERS__0__NAME=a",
+		"APP_TAGS=[x,y]",
+		"APP_URL=http://x?a=1,b=2",
+		"APP_=nothing",
+		"APP_LOG_LEVEL=debug",
+		`APP_PATH=C:\dir\x`,
+		"APP_OBJ={a=1,b=[2]}",
+		`APP_QUOTED="[x]"`,
+	}
+	n, err := FromEnv(environ, EnvOptions{Prefix: "APP_"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := n.String(); s != `{"db":{"host":"localhost","port":"5432"},"log_level":"debug","obj":{"a":"1","b":["2"]},"path":"C:\\dir\\x","quoted":"[x]","servers":[{"name":"a"},{"name":"b"}],"tags":["x","y"],"url":"http://x?a=1,b=2"}` {
+		t.Error(s)
+	}
+	n, err = FromEnv(environ, EnvOptions{Prefix: "APP_DB", PreserveCase: true, Typed: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := n.String(); s != `{"HOST":"localhost","PORT":5432}` {
+		t.Error(s)
+	}
+	n, err = FromEnv([]string{"APP_LOG_LEVEL=debug"}, EnvOptions{Prefix: "APP", Separator: "_"})
+	if err != nil || n.String() != `{"log":{"level":"debug"}}` {
+		t.Error(n, err)
+	}
+	n, err = FromEnv([]string{"APPLE=1", "APP__X=2", "APP_X=3", "APP_=4"}, EnvOptions{Prefix: "APP", Separator: "_"})
+	if err != nil || n.String() != `{"x":"3"}` {
+		t.Error(n, err)
+	}
+	n, err = FromEnv([]string{"APPLE=1", "APP__X=2", "APP___X=3", "APP=4"}, EnvOptions{Prefix: "APP"})
+	if err != nil || n.String() != `{"x":"2"}` {
+		t.Error(n, err)
+	}
+	n, err = FromEnv([]string{"APP__X=2", "APP_X=3"}, EnvOptions{Prefix: "APP_"})
+	if err != nil || n.String() != `{"x":"3"}` {
+		t.Error(n, err)
+	}
+}
+
+func TestFromEnvErrors(t *testing.T) {
+	for _, kv := range []string{"APP_A____B=1", "APP_A=[x", "APP_A=\"x\"y", "APP_A__99999999=1", `APP_A={"a":1}`} {
+		if _, err := FromEnv([]string{kv}, EnvOptions{Prefix: "APP_"}); err == nil {
+			t.Error(kv)
+		}
+	}
+}
+
+func TestFromFlags(t *testing.T) {
+	n, err := FromFlags([]string{"spec.replicas=3,image=nginx", "spec.replicas=4", "args[0]=x"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := n.String(); s != `{"args":["x"],"image":"nginx","spec":{"replicas":4}}` {
+		t.Error(s)
+	}
+	file, _ := FromJSON([]byte(`{"spec":{"replicas":1,"name":"web"}}`))
+	if err := MergeFlags(file, []string{"spec.replicas=2"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if s := file.String(); s != `{"spec":{"name":"web","replicas":"2"}}` {
+		t.Error(s)
+	}
+	_, err = FromFlags([]string{"a=1", "b"}, false)
+	var se *FlatSyntaxError
+	if !errors.As(err, &se) || se.Offset != 1 {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,404 @@
+package jnode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExtJSONOptions control the conversion between Nodes and MongoDB
+// Extended JSON or BSON.
+type ExtJSONOptions struct {
+	// Relaxed selects relaxed rather than canonical Extended JSON
+	// output.  It has no effect when decoding.
+	Relaxed bool
+	// KeepWrappers leaves ObjectIds and dates as their Extended
+	// JSON Objects when decoding, instead of converting them to Text,
+	// so they are encoded with their original types again.
+	KeepWrappers bool
+}
+
+// extDateFormat is RFC 3339 with at most millisecond precision.
+const extDateFormat = "2006-01-02T15:04:05.999Z07:00"
+
+// FromExtJSON creates a Node from MongoDB Extended JSON (canonical or
+// relaxed.)  $numberInt, $numberLong and $numberDouble values become
+// int32, int64 and float64 Numbers, $binary values of subtype 0 or 2
+// become Binary, and $oid and $date values become Text (hex and RFC 3339)
+// unless opts.KeepWrappers is set.  Other wrappers are kept in their
+// canonical form.  Plain integers become int32 or int64 depending on
+// their size, and other plain numbers float64.
+func FromExtJSON(data []byte, opts ExtJSONOptions) (*Node, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var raw interface{}
+	if err := dec.Decode(&raw); err != nil {
+		return MissingNode, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return MissingNode, fmt.Errorf("extjson: extra data after value")
+	}
+	v, err := fromExtJSON(raw, opts)
+	if err != nil {
+		return MissingNode, err
+	}
+	return &Node{v}, nil
+}
+
+func fromExtJSON(raw interface{}, opts ExtJSONOptions) (interface{}, error) {
+	switch v := raw.(type) {
+	case map[string]interface{}:
+		if bv, ok, err := parseExtWrapper(v); ok {
+			if err != nil {
+				return nil, err
+			}
+			if bv.kind == bsonCodeScope {
+				scope, err := fromExtJSON(bv.scope, opts)
+				if err != nil {
+					return nil, err
+				}
+				bv.scope = scope.(map[string]interface{})
+			}
+			return bv.nodeValue(opts), nil
+		}
+		for k, e := range v {
+			x, err := fromExtJSON(e, opts)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = x
+		}
+		return v, nil
+	case []interface{}:
+		for i, e := range v {
+			x, err := fromExtJSON(e, opts)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = x
+		}
+		return &v, nil
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			if i >= math.MinInt32 && i <= math.MaxInt32 {
+				return int32(i), nil
+			}
+			return i, nil
+		}
+		return v.Float64()
+	default:
+		return v, nil
+	}
+}
+
+// ToExtJSON writes a Node as MongoDB Extended JSON, canonical unless
+// opts.Relaxed is set.  Numbers and Binary Nodes are written as BSON
+// would store them (see MarshalBSON), and Extended JSON Objects in the
+// Node are normalized to the selected format.
+func (n *Node) ToExtJSON(opts ExtJSONOptions) ([]byte, error) {
+	v, err := toExtJSON(n.value, opts.Relaxed)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+func toExtJSON(value interface{}, relaxed bool) (interface{}, error) {
+	switch v := value.(type) {
+	case *[]interface{}:
+		a := make([]interface{}, len(*v))
+		for i, e := range *v {
+			x, err := toExtJSON(e, relaxed)
+			if err != nil {
+				return nil, err
+			}
+			a[i] = x
+		}
+		return a, nil
+	case map[string]interface{}:
+		if _, ok, _ := parseExtWrapper(v); !ok {
+			m := make(map[string]interface{}, len(v))
+			for k, e := range v {
+				x, err := toExtJSON(e, relaxed)
+				if err != nil {
+					return nil, err
+				}
+				m[k] = x
+			}
+			return m, nil
+		}
+	}
+	bv, err := toBSONValue(value)
+	if err != nil {
+		return nil, err
+	}
+	x := bv.extJSON(relaxed)
+	if bv.kind == bsonCodeScope {
+		if x.(map[string]interface{})["$scope"], err = toExtJSON(bv.scope, relaxed); err != nil {
+			return nil, err
+		}
+	}
+	return x, nil
+}
+
+// extJSON returns the Extended JSON form of a BSON value, using Node
+// values (strings, int64s and maps) for the contents of wrappers.
+func (v bsonValue) extJSON(relaxed bool) interface{} {
+	wrap := func(k string, x interface{}) map[string]interface{} {
+		return map[string]interface{}{k: x}
+	}
+	switch v.kind {
+	case bsonDouble:
+		s := formatFloat(v.f, 64)
+		switch {
+		case math.IsInf(v.f, 1):
+			s = "Infinity"
+		case math.IsInf(v.f, -1):
+			s = "-Infinity"
+		case math.IsNaN(v.f):
+			s = "NaN"
+		case !strings.ContainsAny(s, ".e"):
+			s += ".0"
+		}
+		if relaxed && !math.IsInf(v.f, 0) && !math.IsNaN(v.f) {
+			return json.Number(s)
+		}
+		return wrap("$numberDouble", s)
+	case bsonString:
+		return v.s
+	case bsonBinary:
+		return wrap("$binary", map[string]interface{}{
+			"base64":  base64.StdEncoding.EncodeToString(v.b),
+			"subType": fmt.Sprintf("%02x", v.sub),
+		})
+	case bsonUndefined:
+		return wrap("$undefined", true)
+	case bsonObjectID:
+		return wrap("$oid", v.s)
+	case bsonBool:
+		return v.i == 1
+	case bsonDateTime:
+		t := time.UnixMilli(v.i).UTC()
+		if relaxed && t.Year() >= 1970 && t.Year() <= 9999 {
+			return wrap("$date", t.Format(extDateFormat))
+		}
+		return wrap("$date", wrap("$numberLong", strconv.FormatInt(v.i, 10)))
+	case bsonNull:
+		return nil
+	case bsonRegex:
+		return wrap("$regularExpression", map[string]interface{}{"pattern": v.s, "options": v.s2})
+	case bsonDBPointer:
+		return wrap("$dbPointer", map[string]interface{}{"$ref": v.s, "$id": wrap("$oid", v.s2)})
+	case bsonCode:
+		return wrap("$code", v.s)
+	case bsonSymbol:
+		return wrap("$symbol", v.s)
+	case bsonCodeScope:
+		return map[string]interface{}{"$code": v.s, "$scope": v.scope}
+	case bsonInt32, bsonInt64:
+		s := strconv.FormatInt(v.i, 10)
+		if relaxed {
+			return json.Number(s)
+		}
+		if v.kind == bsonInt32 {
+			return wrap("$numberInt", s)
+		}
+		return wrap("$numberLong", s)
+	case bsonTimestamp:
+		return wrap("$timestamp", map[string]interface{}{
+			"t": int64(uint64(v.i) >> 32), "i": int64(uint32(v.i)),
+		})
+	case bsonDecimal128:
+		return wrap("$numberDecimal", v.s)
+	case bsonMinKey:
+		return wrap("$minKey", int64(1))
+	case bsonMaxKey:
+		return wrap("$maxKey", int64(1))
+	}
+	panic(fmt.Sprintf("unknown BSON type 0x%02x", v.kind))
+}
+
+// nodeValue returns the Node value that a decoded BSON value becomes.
+func (v bsonValue) nodeValue(opts ExtJSONOptions) interface{} {
+	switch v.kind {
+	case bsonDouble:
+		return v.f
+	case bsonString:
+		return v.s
+	case bsonInt32:
+		return int32(v.i)
+	case bsonInt64:
+		return v.i
+	case bsonBool:
+		return v.i == 1
+	case bsonNull, bsonUndefined:
+		return nil
+	case bsonBinary:
+		if v.sub == 0 || v.sub == 0x02 {
+			return v.b
+		}
+	case bsonObjectID:
+		if !opts.KeepWrappers {
+			return v.s
+		}
+	case bsonDateTime:
+		t := time.UnixMilli(v.i).UTC()
+		if !opts.KeepWrappers && t.Year() >= 0 && t.Year() <= 9999 {
+			return t.Format(extDateFormat)
+		}
+	}
+	return v.extJSON(false)
+}
+
+// parseExtWrapper recognizes an Extended JSON Object by its keys.
+// If it is recognized but its contents are invalid, it returns ok
+// and an error.
+func parseExtWrapper(m map[string]interface{}) (v bsonValue, ok bool, err error) {
+	var key string
+	switch len(m) {
+	case 1:
+		for key = range m {
+		}
+	case 2:
+		if _, hasScope := m["$scope"]; !hasScope {
+			return v, false, nil
+		}
+		key = "$scope"
+	default:
+		return v, false, nil
+	}
+	x := m[key]
+	bad := func() (bsonValue, bool, error) {
+		return v, true, fmt.Errorf("extjson: invalid %s value %v", key, &Node{x})
+	}
+	str, isStr := x.(string)
+	sub, _ := x.(map[string]interface{})
+	switch key {
+	case "$oid":
+		v.kind, v.s = bsonObjectID, strings.ToLower(str)
+		if b, err := hex.DecodeString(str); !isStr || err != nil || len(b) != 12 {
+			return bad()
+		}
+	case "$date":
+		v.kind = bsonDateTime
+		if isStr {
+			t, err := time.Parse(time.RFC3339Nano, str)
+			if err != nil {
+				return bad()
+			}
+			v.i = t.UnixMilli()
+		} else if i, ok := extInt(x); ok {
+			v.i = i
+		} else if l, ok := sub["$numberLong"].(string); ok && len(sub) == 1 {
+			if v.i, err = strconv.ParseInt(l, 10, 64); err != nil {
+				return bad()
+			}
+		} else {
+			return bad()
+		}
+	case "$numberLong", "$numberInt":
+		bits := 64
+		v.kind = bsonInt64
+		if key == "$numberInt" {
+			bits, v.kind = 32, bsonInt32
+		}
+		if v.i, err = strconv.ParseInt(str, 10, bits); !isStr || err != nil {
+			return bad()
+		}
+	case "$numberDouble":
+		v.kind = bsonDouble
+		if v.f, err = strconv.ParseFloat(str, 64); !isStr || err != nil {
+			return bad()
+		}
+	case "$numberDecimal":
+		v.kind, v.s = bsonDecimal128, str
+		if _, _, err := parseDecimal128(str); !isStr || err != nil {
+			return bad()
+		}
+	case "$binary":
+		v.kind = bsonBinary
+		b64, ok1 := sub["base64"].(string)
+		st, ok2 := sub["subType"].(string)
+		s, err1 := strconv.ParseUint(st, 16, 8)
+		b, err2 := base64.StdEncoding.DecodeString(b64)
+		if len(sub) != 2 || !ok1 || !ok2 || len(st) > 2 || err1 != nil || err2 != nil {
+			return bad()
+		}
+		v.b, v.sub = b, byte(s)
+	case "$timestamp":
+		v.kind = bsonTimestamp
+		t, ok1 := extInt(sub["t"])
+		i, ok2 := extInt(sub["i"])
+		if len(sub) != 2 || !ok1 || !ok2 || t < 0 || t > math.MaxUint32 || i < 0 || i > math.MaxUint32 {
+			return bad()
+		}
+		v.i = int64(uint64(t)<<32 | uint64(i))
+	case "$regularExpression":
+		v.kind = bsonRegex
+		p, ok1 := sub["pattern"].(string)
+		o, ok2 := sub["options"].(string)
+		if len(sub) != 2 || !ok1 || !ok2 {
+			return bad()
+		}
+		v.s, v.s2 = p, o
+	case "$dbPointer":
+		v.kind = bsonDBPointer
+		ref, ok1 := sub["$ref"].(string)
+		id, ok2 := sub["$id"].(map[string]interface{})
+		oid, ok3, err := parseExtWrapper(id)
+		if len(sub) != 2 || !ok1 || !ok2 || !ok3 || err != nil || oid.kind != bsonObjectID {
+			return bad()
+		}
+		v.s, v.s2 = ref, oid.s
+	case "$code", "$symbol":
+		v.kind, v.s = bsonCode, str
+		if key == "$symbol" {
+			v.kind = bsonSymbol
+		}
+		if !isStr {
+			return bad()
+		}
+	case "$scope":
+		code, ok := m["$code"].(string)
+		if !ok {
+			return v, false, nil
+		}
+		if sub == nil {
+			return bad()
+		}
+		v.kind, v.s, v.scope = bsonCodeScope, code, sub
+	case "$minKey", "$maxKey":
+		v.kind = bsonMinKey
+		if key == "$maxKey" {
+			v.kind = bsonMaxKey
+		}
+		if i, ok := extInt(x); !ok || i != 1 {
+			return bad()
+		}
+	case "$undefined":
+		v.kind = bsonUndefined
+		if x != true {
+			return bad()
+		}
+	default:
+		return v, false, nil
+	}
+	return v, true, nil
+}
+
+// extInt returns the value of an integral Number.
+func extInt(x interface{}) (int64, bool) {
+	n := &Node{x}
+	if n.GetType() != Number {
+		return 0, false
+	}
+	i, err := As[int64](n)
+	return i, err == nil
+}
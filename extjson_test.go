@@ -0,0 +1,108 @@
+package jnode
+
+import (
+	"testing"
+)
+
+func TestFromExtJSON(t *testing.T) {
+	n, err := FromExtJSON([]byte(`{
+		"_id": {"$oid": "5F1A2B3C4D5E6F7081920A1B"},
+		"d1": {"$date": {"$numberLong": "0"}},
+		"d2": {"$date": "2020-09-13T12:26:40.123+02:00"},
+		"l": {"$numberLong": "9007199254740993"},
+		"i": {"$numberInt": "-7"},
+		"f": {"$numberDouble": "-Infinity"},
+		"b": {"$binary": {"base64": "AQI=", "subType": "00"}},
+		"plain": [1, 5000000000, 1.0],
+		"dollar": {"$notAType": 1}
+	}`), ExtJSONOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]string{
+		"_id":    `"5f1a2b3c4d5e6f7081920a1b"`,
+		"d1":     `"1970-01-01T00:00:00Z"`,
+		"d2":     `"2020-09-13T10:26:40.123Z"`,
+		"l":      `9007199254740993`,
+		"i":      `-7`,
+		"b":      `"AQI="`,
+		"plain":  `[1,5000000000,1]`,
+		"dollar": `{"$notAType":1}`,
+	}
+	for k, e := range expect {
+		if s := n.Path(k).String(); s != e {
+			t.Errorf("%s: %s", k, s)
+		}
+	}
+	if _, ok := n.Path("l").Unwrap().(int64); !ok {
+		t.Errorf("%T", n.Path("l").Unwrap())
+	}
+	if _, ok := n.Path("plain").Get(0).Unwrap().(int32); !ok {
+		t.Errorf("%T", n.Path("plain").Get(0).Unwrap())
+	}
+	if _, ok := n.Path("plain").Get(2).Unwrap().(float64); !ok {
+		t.Errorf("%T", n.Path("plain").Get(2).Unwrap())
+	}
+	if n.Path("b").GetType() != Binary || n.Path("f").AsFloat() > 0 {
+		t.Error(n)
+	}
+	n, _ = FromExtJSON([]byte(`{"_id": {"$oid": "5f1a2b3c4d5e6f7081920a1b"}}`), ExtJSONOptions{KeepWrappers: true})
+	if s := n.String(); s != `{"_id":{"$oid":"5f1a2b3c4d5e6f7081920a1b"}}` {
+		t.Error(s)
+	}
+}
+
+func TestFromExtJSONErrors(t *testing.T) {
+	for _, s := range []string{
+		`{"$oid": "xyz"}`, `{"$numberInt": "5000000000"}`, `{"$numberLong": 5}`,
+		`{"$date": "yesterday"}`, `{"$binary": {"base64": "!"}}`, `{"$timestamp": {"t": -1, "i": 0}}`,
+		`{"$minKey": 2}`, `{"$code": "x", "$scope": 1}`, `{} {}`,
+	} {
+		if _, err := FromExtJSON([]byte(s), ExtJSONOptions{}); err == nil {
+			t.Errorf("%s: expected error", s)
+		}
+	}
+}
+
+func TestToExtJSON(t *testing.T) {
+	n := NewObjectNode().Put("i", 1).Put("l", int64(2)).Put("f", 3.0).Put("b", []byte{1}).
+		Put("s", "x").Put("big", 1<<40)
+	n.Put("id", mustJSON(t, `{"$oid": "5f1a2b3c4d5e6f7081920a1b"}`))
+	n.Put("d", mustJSON(t, `{"$date": "2020-09-13T12:26:40.123Z"}`))
+	n.Put("old", mustJSON(t, `{"$date": {"$numberLong": "-1"}}`))
+	n.PutArray("a").Append(mustJSON(t, `{"$timestamp": {"t": 1, "i": 2}}`))
+	canonical, err := n.ToExtJSON(ExtJSONOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(canonical); s != `{"a":[{"$timestamp":{"i":2,"t":1}}],"b":{"$binary":{"base64":"AQ==","subType":"00"}},`+
+		`"big":{"$numberLong":"1099511627776"},"d":{"$date":{"$numberLong":"1600000000123"}},"f":{"$numberDouble":"3.0"},`+
+		`"i":{"$numberInt":"1"},"id":{"$oid":"5f1a2b3c4d5e6f7081920a1b"},"l":{"$numberLong":"2"},`+
+		`"old":{"$date":{"$numberLong":"-1"}},"s":"x"}` {
+		t.Error(s)
+	}
+	relaxed, err := n.ToExtJSON(ExtJSONOptions{Relaxed: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(relaxed); s != `{"a":[{"$timestamp":{"i":2,"t":1}}],"b":{"$binary":{"base64":"AQ==","subType":"00"}},`+
+		`"big":1099511627776,"d":{"$date":"2020-09-13T12:26:40.123Z"},"f":3.0,"i":1,`+
+		`"id":{"$oid":"5f1a2b3c4d5e6f7081920a1b"},"l":2,"old":{"$date":{"$numberLong":"-1"}},"s":"x"}` {
+		t.Error(s)
+	}
+	m, err := FromExtJSON(canonical, ExtJSONOptions{KeepWrappers: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := m.ToExtJSON(ExtJSONOptions{}); string(again) != string(canonical) {
+		t.Error(string(again))
+	}
+	// relaxed output loses the integer sizes, but not dates or doubles
+	m, err = FromExtJSON(relaxed, ExtJSONOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Path("f").Unwrap().(float64); !ok || m.Path("d").AsText() != "2020-09-13T12:26:40.123Z" {
+		t.Error(m)
+	}
+}
@@ -1,99 +1,806 @@
 package jnode
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
+// maxFlatIndex bounds the array indices accepted in flat string field
+// names, so a typo can't allocate an enormous array.
+const maxFlatIndex = 65535
+
+// FlatSyntaxError describes a syntax error in a flat string.  Offset
+// is the byte offset in the input at which parsing failed.
+type FlatSyntaxError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *FlatSyntaxError) Error() string {
+	return fmt.Sprintf("%s at offset %d", e.Msg, e.Offset)
+}
+
 // FromFlatString converts a string into an object Node.  It accepts a string in the form:
 //
 //     field1=value1,field2=value2,....
 //     field1={subobj_field1=value1,...}
 //     field1=[list_value1,list_value2]
+//     field1.subfield=value1,list[1]=value2
 //
 // The first form sets the fields and values of the object.  The second form sets the
-// fields and values of a nested object.  The final form sets the values of an array.
+// fields and values of a nested object.  The third form sets the values of an array.
+// The final form uses dotted field names and bracketed indices to set values
+// deep inside the object, creating intermediate objects and arrays as needed
+// (see MergeFlatString.)
+//
+// Values may be double or single quoted, in which case they can contain
+// any character.  Inside double quotes the JSON escapes (\", \\, \n, \t,
+// \uXXXX etc.) are recognized; inside single quotes only \' and \\ are.
+// Outside of quotes a backslash escapes the following character, so
+// "url=http://x?a=1\,b=2" and "a\.b=1" (a field named "a.b") work as
+// expected.  A field name component may also be quoted.  "[]" and "{}"
+// are an empty array and an empty object respectively, while "a=" sets
+// a to the empty string.
+//
+// Syntax errors are returned as a *FlatSyntaxError.
 func FromFlatString(s string) (*Node, error) {
 	node := NewObjectNode()
-	for len(s) > 0 {
-		n, err := parseAssignment(node, s, ",\x00")
-		if err != nil {
-			return nil, err
+	if err := MergeFlatString(node, s); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// FromFlatStringTyped is like FromFlatString, except that the types of
+// unquoted values are inferred.  "null" becomes a null, "true" and
+// "false" become booleans, and JSON style numbers become int64, uint64
+// (for integers too large for an int64) or float64 values.  Quoted values
+// are always strings.
+//
+// A field name may also end with a type hint that forces the type of its
+// value:
+//
+//     port:int=8080,ratio:float=1,debug:bool=yes,version:str=1.10
+//
+// The hints are str (or string), int, float, bool and null.  A hint on an
+// array applies to each of its elements.  A value that can't be converted
+// to the hinted type is a syntax error.
+func FromFlatStringTyped(s string) (*Node, error) {
+	node := NewObjectNode()
+	if err := MergeFlatStringTyped(node, s); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// MergeFlatString applies the assignments in s (in the format accepted by
+// FromFlatString) to an existing object Node.  Assignments with dotted or
+// indexed field names merge into the objects and arrays already present
+// in the node, so "a.b=1,a.c=2" results in {"a":{"b":"1","c":"2"}}.
+// Arrays are padded with nulls when an index is beyond their end.  An
+// intermediate value that is not of the right type (e.g. a string where
+// an object is needed) is replaced.
+func MergeFlatString(node *Node, s string) error {
+	if !node.IsObject() {
+		return fmt.Errorf("not an object")
+	}
+	p := &flatParser{s: s}
+	return p.parseAssignments(node, 0)
+}
+
+// MergeFlatStringTyped is like MergeFlatString but infers the type
+// of values (see FromFlatStringTyped.)
+func MergeFlatStringTyped(node *Node, s string) error {
+	if !node.IsObject() {
+		return fmt.Errorf("not an object")
+	}
+	p := &flatParser{s: s, typed: true}
+	return p.parseAssignments(node, 0)
+}
+
+type flatParser struct {
+	s     string
+	pos   int
+	typed bool
+}
+
+func (p *flatParser) errorf(format string, args ...interface{}) error {
+	return &FlatSyntaxError{Offset: p.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *flatParser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+// peek returns the next byte of input, or 0 at the end of the input.
+func (p *flatParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+// unexpected returns an error for the next byte of input.
+func (p *flatParser) unexpected(expected string) error {
+	if p.eof() {
+		return p.errorf("unexpected end of input, expected %s", expected)
+	}
+	return p.errorf("unexpected %q, expected %s", p.s[p.pos], expected)
+}
+
+// parseAssignments parses a comma separated list of assignments
+// into node.  If end is not 0 then the list must be terminated
+// by end (and may be empty), otherwise the list extends to the
+// end of the input.
+func (p *flatParser) parseAssignments(node *Node, end byte) error {
+	if end != 0 && p.peek() == end {
+		p.pos++
+		return nil
+	}
+	for !p.eof() || end != 0 {
+		if err := p.parseAssignment(node, end); err != nil {
+			return err
 		}
-		if len(s) == n {
-			break
+		switch {
+		case end == 0 && p.eof():
+			return nil
+		case p.peek() == ',':
+			p.pos++
+		case end != 0 && p.peek() == end:
+			p.pos++
+			return nil
+		default:
+			if end == 0 {
+				return p.unexpected("','")
+			}
+			return p.unexpected(fmt.Sprintf("',' or '%c'", end))
 		}
-		s = s[n+1:]
 	}
-	return node, nil
+	return nil
 }
 
-func parseAssignment(node *Node, s, terms string) (int, error) {
-	eq := strings.Index(s, "=")
-	if eq < 0 {
-		return 0, fmt.Errorf("missing '=' in assignment")
+func (p *flatParser) parseAssignment(node *Node, end byte) error {
+	key, hint, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	terms := ","
+	if end != 0 {
+		terms += string(end)
 	}
-	name := s[0:eq]
-	val, n, err := parseValue(s[eq+1:], terms)
+	val, err := p.parseValue(terms, hint)
 	if err != nil {
-		return 0, err
+		return err
+	}
+	putKey(node, key, val)
+	return nil
+}
+
+// parseKey parses a field name like "a.b[1].c" and the following '='.
+// The components of the name are returned as strings (field names)
+// or ints (array indices), along with the type hint (if any.)
+func (p *flatParser) parseKey() ([]interface{}, string, error) {
+	var key []interface{}
+	for {
+		start := p.pos
+		field, err := p.parseField()
+		if err != nil {
+			return nil, "", err
+		}
+		if p.pos == start {
+			return nil, "", p.unexpected("field name")
+		}
+		key = append(key, field)
+		for p.peek() == '[' {
+			p.pos++
+			i, err := p.parseIndex()
+			if err != nil {
+				return nil, "", err
+			}
+			key = append(key, i)
+		}
+		hint := ""
+		if p.typed && p.peek() == ':' {
+			if hint, err = p.parseHint(); err != nil {
+				return nil, "", err
+			}
+		}
+		switch p.peek() {
+		case '.':
+			if hint == "" {
+				p.pos++
+				continue
+			}
+		case '=':
+			p.pos++
+			return key, hint, nil
+		}
+		return nil, "", p.unexpected("'=' after field name")
+	}
+}
+
+func (p *flatParser) parseHint() (string, error) {
+	p.pos++
+	start := p.pos
+	for !p.eof() && p.s[p.pos] >= 'a' && p.s[p.pos] <= 'z' {
+		p.pos++
+	}
+	switch hint := p.s[start:p.pos]; hint {
+	case "str", "string", "int", "float", "bool", "null":
+		return hint, nil
+	default:
+		p.pos = start
+		return "", p.errorf("unknown type hint %q", hint)
+	}
+}
+
+func (p *flatParser) parseField() (string, error) {
+	if c := p.peek(); c == '"' || c == '\'' {
+		return p.parseQuoted()
 	}
-	node.Put(name, val)
-	return eq + n + 1, nil
+	var sb strings.Builder
+	for !p.eof() {
+		c := p.s[p.pos]
+		switch c {
+		case '\\':
+			if err := p.parseBareEscape(&sb); err != nil {
+				return "", err
+			}
+			continue
+		case '.', '[', '=', ',', '{', '}', ']':
+			return sb.String(), nil
+		case ':':
+			if p.typed {
+				return sb.String(), nil
+			}
+		}
+		sb.WriteByte(c)
+		p.pos++
+	}
+	return sb.String(), nil
 }
 
-func parseValue(s, terms string) (interface{}, int, error) {
-	if len(s) == 0 {
-		return "", 0, nil
+func (p *flatParser) parseIndex() (int, error) {
+	start := p.pos
+	for !p.eof() && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.pos == start {
+		return 0, p.unexpected("array index")
+	}
+	digits := p.s[start:p.pos]
+	i, err := strconv.Atoi(digits)
+	if err != nil || i > maxFlatIndex {
+		p.pos = start
+		return 0, p.errorf("array index %s is too large", digits)
 	}
-	switch s[0] {
+	if p.peek() != ']' {
+		return 0, p.unexpected("']'")
+	}
+	p.pos++
+	return i, nil
+}
+
+// parseValue parses a value, which may be a nested array or object,
+// a quoted string, or a bare string terminated by one of the characters
+// in terms (or the end of the input.)  Scalar values are converted
+// according to hint.
+func (p *flatParser) parseValue(terms, hint string) (interface{}, error) {
+	start := p.pos
+	switch p.peek() {
 	case '[':
-		val, n, err := parseArray(s[1:])
-		return val, n + 1, err
+		p.pos++
+		return p.parseArray(hint)
 	case '{':
-		val, n, err := parseObject(s[1:])
-		return val, n + 1, err
+		if hint != "" {
+			return nil, p.errorf("type hint %s cannot be applied to an object", hint)
+		}
+		p.pos++
+		obj := NewObjectNode()
+		if err := p.parseAssignments(obj, '}'); err != nil {
+			return nil, err
+		}
+		return obj, nil
+	case '"', '\'':
+		s, err := p.parseQuoted()
+		if err != nil {
+			return nil, err
+		}
+		if !p.eof() && strings.IndexByte(terms, p.s[p.pos]) < 0 {
+			return nil, p.errorf("unexpected %q after quoted value", p.s[p.pos])
+		}
+		if hint != "" {
+			return p.convert(start, s, hint)
+		}
+		return s, nil
 	default:
-		for i, ch := range s {
-			if strings.ContainsRune(terms, ch) {
-				return s[0:i], i, nil
+		var sb strings.Builder
+		for !p.eof() {
+			c := p.s[p.pos]
+			if c == '\\' {
+				if err := p.parseBareEscape(&sb); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if strings.IndexByte(terms, c) >= 0 {
+				break
 			}
+			sb.WriteByte(c)
+			p.pos++
 		}
-		if !strings.Contains(terms, "\x00") {
-			return nil, 0, fmt.Errorf("unexpected end of input")
+		if hint == "" && p.typed {
+			return inferValue(sb.String()), nil
 		}
-		return s, len(s), nil
+		return p.convert(start, sb.String(), hint)
 	}
 }
 
-func parseArray(s string) (interface{}, int, error) {
+// convert converts a string to the type named by hint, reporting
+// errors at offset start.
+func (p *flatParser) convert(start int, s, hint string) (interface{}, error) {
+	var val interface{}
+	switch hint {
+	case "", "str", "string":
+		return s, nil
+	case "int":
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			val = i
+		} else if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+			val = u
+		}
+	case "float":
+		if isJSONNumber(s) {
+			if f, err := strconv.ParseFloat(s, 64); err == nil {
+				val = f
+			}
+		}
+	case "bool":
+		if b, err := strconv.ParseBool(s); err == nil {
+			val = b
+		} else if strings.EqualFold(s, "yes") || strings.EqualFold(s, "on") {
+			val = true
+		} else if strings.EqualFold(s, "no") || strings.EqualFold(s, "off") {
+			val = false
+		}
+	case "null":
+		if s == "" || s == "null" {
+			return nil, nil
+		}
+	}
+	if val == nil {
+		p.pos = start
+		return nil, p.errorf("%q is not a valid %s", s, hint)
+	}
+	return val, nil
+}
+
+// inferValue returns the null, bool or numeric value of a bare
+// string, or the string itself.
+func inferValue(s string) interface{} {
+	switch s {
+	case "null":
+		return nil
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if !isJSONNumber(s) {
+		return s
+	}
+	if strings.IndexAny(s, ".eE") < 0 {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i
+		}
+		if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return u
+		}
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}
+
+// isJSONNumber returns true if s is a number according to the
+// JSON grammar (so "1.5e3" is but "007", "0x10" and "Inf" are not.)
+func isJSONNumber(s string) bool {
+	i := 0
+	digits := func() int {
+		n := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+			n++
+		}
+		return n
+	}
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+	start := i
+	n := digits()
+	if n == 0 || (n > 1 && s[start] == '0') {
+		return false
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		if digits() == 0 {
+			return false
+		}
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		if digits() == 0 {
+			return false
+		}
+	}
+	return i == len(s)
+}
+
+func (p *flatParser) parseArray(hint string) (interface{}, error) {
 	a := NewArrayNode()
-	k := 0
+	if p.peek() == ']' {
+		p.pos++
+		return a, nil
+	}
 	for {
-		val, n, err := parseValue(s[k:], ",]")
+		val, err := p.parseValue(",]", hint)
 		if err != nil {
-			return nil, 0, err
+			return nil, err
 		}
-		k += n
 		a.Append(val)
-		if s[k] == ']' {
-			return a, k + 1, nil
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return a, nil
+		default:
+			return nil, p.unexpected("',' or ']'")
 		}
 	}
 }
 
-func parseObject(s string) (interface{}, int, error) {
-	obj := NewObjectNode()
-	k := 0
-	for {
-		n, err := parseAssignment(obj, s[k:], ",}")
+// parseBareEscape handles a backslash outside of quotes, which
+// escapes the next character.
+func (p *flatParser) parseBareEscape(sb *strings.Builder) error {
+	p.pos++
+	if p.eof() {
+		return p.errorf("unexpected end of input after '\\'")
+	}
+	_, size := utf8.DecodeRuneInString(p.s[p.pos:])
+	sb.WriteString(p.s[p.pos : p.pos+size])
+	p.pos += size
+	return nil
+}
+
+func (p *flatParser) parseQuoted() (string, error) {
+	start := p.pos
+	quote := p.s[p.pos]
+	p.pos++
+	var sb strings.Builder
+	for !p.eof() {
+		c := p.s[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return sb.String(), nil
+		case c == '\\':
+			if err := p.parseQuotedEscape(&sb, quote); err != nil {
+				return "", err
+			}
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+	p.pos = start
+	return "", p.errorf("unterminated quoted string")
+}
+
+func (p *flatParser) parseQuotedEscape(sb *strings.Builder, quote byte) error {
+	p.pos++
+	if p.eof() {
+		return p.errorf("unexpected end of input after '\\'")
+	}
+	c := p.s[p.pos]
+	if quote == '\'' {
+		if c != '\'' && c != '\\' {
+			return p.errorf("invalid escape '\\%c' in single quoted string", c)
+		}
+		sb.WriteByte(c)
+		p.pos++
+		return nil
+	}
+	switch c {
+	case '"', '\\', '/', '\'':
+		sb.WriteByte(c)
+	case 'b':
+		sb.WriteByte('\b')
+	case 'f':
+		sb.WriteByte('\f')
+	case 'n':
+		sb.WriteByte('\n')
+	case 'r':
+		sb.WriteByte('\r')
+	case 't':
+		sb.WriteByte('\t')
+	case 'u':
+		if p.pos+5 > len(p.s) {
+			return p.errorf("invalid unicode escape")
+		}
+		r, err := strconv.ParseUint(p.s[p.pos+1:p.pos+5], 16, 16)
 		if err != nil {
-			return nil, 0, err
+			return p.errorf("invalid unicode escape")
 		}
-		k += n
-		if s[k] == '}' {
-			return obj, k + 1, nil
+		sb.WriteRune(rune(r))
+		p.pos += 4
+	default:
+		return p.errorf("invalid escape '\\%c'", c)
+	}
+	p.pos++
+	return nil
+}
+
+// putKey sets the value at key within node, creating (or replacing)
+// intermediate objects and arrays as required.
+func putKey(node *Node, key []interface{}, val interface{}) {
+	for i, k := range key {
+		last := i == len(key)-1
+		var child *Node
+		switch k := k.(type) {
+		case string:
+			if last {
+				node.Put(k, val)
+				return
+			}
+			child = node.Path(k)
+			if !containerFor(child, key[i+1]) {
+				child = newContainerFor(key[i+1])
+				node.Put(k, child)
+			}
+		case int:
+			for node.Size() <= k {
+				node.Append(nil)
+			}
+			if last {
+				node.Set(k, val)
+				return
+			}
+			child = node.Get(k)
+			if !containerFor(child, key[i+1]) {
+				child = newContainerFor(key[i+1])
+				node.Set(k, child)
+			}
 		}
-		k++
+		node = child
+	}
+}
+
+func containerFor(n *Node, k interface{}) bool {
+	if _, ok := k.(int); ok {
+		return n.IsArray()
+	}
+	return n.IsObject()
+}
+
+func newContainerFor(k interface{}) *Node {
+	if _, ok := k.(int); ok {
+		return NewArrayNode()
+	}
+	return NewObjectNode()
+}
+
+// ToFlatString returns an object Node in the format accepted by
+// FromFlatString, using nested {} and [] for objects and arrays.  Fields
+// are written in sorted order.  Strings that would be read back as some
+// other type by FromFlatStringTyped (or that contain special characters)
+// are quoted, so the result can be read back with either FromFlatString
+// or FromFlatStringTyped.  The latter preserves the type of each Node,
+// except that Binary values are read back as Text, and numbers are read
+// back as int64, uint64 or float64 according to how they're written (so
+// a whole number float64 such as 1.0 becomes an int64.)  Returns an error if the Node is not an Object, or if it contains a
+// value that can't be represented (e.g. a NaN.)
+func (n *Node) ToFlatString() (string, error) {
+	return n.toFlatString(false)
+}
+
+// ToFlatStringDotted is like ToFlatString, except that each leaf value
+// is written as a separate assignment with a dotted (and indexed) field
+// name, e.g. "a.b=1,a.c[0]=x".
+func (n *Node) ToFlatStringDotted() (string, error) {
+	return n.toFlatString(true)
+}
+
+func (n *Node) toFlatString(dotted bool) (string, error) {
+	if !n.IsObject() {
+		return "", fmt.Errorf("not an object")
+	}
+	w := &flatWriter{}
+	var err error
+	if dotted {
+		err = w.writeLeaves(n)
+	} else {
+		err = w.writeAssignments(n)
+	}
+	if err != nil {
+		return "", err
+	}
+	return w.sb.String(), nil
+}
+
+type flatWriter struct {
+	sb    strings.Builder
+	count int
+}
+
+func (w *flatWriter) writeAssignments(n *Node) error {
+	entries := n.Entries()
+	for i, k := range sortedKeys(n.ToMap()) {
+		if i > 0 {
+			w.sb.WriteByte(',')
+		}
+		writeFlatField(&w.sb, k)
+		w.sb.WriteByte('=')
+		if err := w.writeValue(entries[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *flatWriter) writeValue(n *Node) error {
+	switch n.GetType() {
+	case Object:
+		w.sb.WriteByte('{')
+		if err := w.writeAssignments(n); err != nil {
+			return err
+		}
+		w.sb.WriteByte('}')
+	case Array:
+		w.sb.WriteByte('[')
+		for i, e := range n.Elements() {
+			if i > 0 {
+				w.sb.WriteByte(',')
+			}
+			if err := w.writeValue(e); err != nil {
+				return err
+			}
+		}
+		w.sb.WriteByte(']')
+	default:
+		return w.writeScalar(n)
+	}
+	return nil
+}
+
+// writeLeaves writes an assignment for each leaf value under n.
+func (w *flatWriter) writeLeaves(n *Node) error {
+	return flatLeaves("", n, true, func(key string, leaf *Node) error {
+		if w.count > 0 {
+			w.sb.WriteByte(',')
+		}
+		w.count++
+		w.sb.WriteString(key)
+		w.sb.WriteByte('=')
+		return w.writeValue(leaf)
+	})
+}
+
+// flatLeaves calls fn for each leaf value under n in sorted order, along
+// with its dotted field name.  A leaf is a scalar, an empty object or an
+// empty array, or any array unless arrays is set.  prefix is the field
+// name of n.
+func flatLeaves(prefix string, n *Node, arrays bool, fn func(key string, leaf *Node) error) error {
+	switch {
+	case n.IsObject() && (n.Size() > 0 || prefix == ""):
+		entries := n.Entries()
+		for _, k := range sortedKeys(n.ToMap()) {
+			var sb strings.Builder
+			sb.WriteString(prefix)
+			if prefix != "" {
+				sb.WriteByte('.')
+			}
+			writeFlatField(&sb, k)
+			if err := flatLeaves(sb.String(), entries[k], arrays, fn); err != nil {
+				return err
+			}
+		}
+	case arrays && n.IsArray() && n.Size() > 0:
+		for i, e := range n.Elements() {
+			if err := flatLeaves(fmt.Sprintf("%s[%d]", prefix, i), e, arrays, fn); err != nil {
+				return err
+			}
+		}
+	default:
+		return fn(prefix, n)
+	}
+	return nil
+}
+
+func (w *flatWriter) writeScalar(n *Node) error {
+	switch n.GetType() {
+	case Null:
+		w.sb.WriteString("null")
+	case Text, Binary:
+		s := n.AsText()
+		if needsFlatQuotes(s) {
+			writeFlatQuoted(&w.sb, s)
+		} else {
+			w.sb.WriteString(s)
+		}
+	default:
+		b, err := json.Marshal(n.value)
+		if err != nil {
+			return err
+		}
+		w.sb.Write(b)
+	}
+	return nil
+}
+
+func writeFlatField(sb *strings.Builder, name string) {
+	if name == "" || strings.ContainsAny(name, ".[]=,{}:\\\"'") || !isFlatPrintable(name) {
+		writeFlatQuoted(sb, name)
+	} else {
+		sb.WriteString(name)
+	}
+}
+
+func needsFlatQuotes(s string) bool {
+	if s == "" || strings.ContainsAny(s, ",[]{}\\\"'") || !isFlatPrintable(s) {
+		return true
+	}
+	_, isString := inferValue(s).(string)
+	return !isString
+}
+
+func isFlatPrintable(s string) bool {
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+func writeFlatQuoted(sb *strings.Builder, s string) {
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\t':
+			sb.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(sb, `\u%04x`, r)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteByte('"')
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
 	}
+	sort.Strings(keys)
+	return keys
 }
@@ -1,6 +1,10 @@
 package jnode
 
 import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
 	"testing"
 )
 
@@ -45,3 +49,301 @@ func TestComplex(t *testing.T) {
 		t.Error(n)
 	}
 }
+
+func TestFromFlatMultiArray(t *testing.T) {
+	n, err := FromFlatString("a=[x,y,z],b=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.String() != `{"a":["x","y","z"],"b":"1"}` {
+		t.Error(n)
+	}
+}
+
+func TestFromFlatDotted(t *testing.T) {
+	n, err := FromFlatString("spec.replicas=3,spec.template.image=nginx,args[1]=x,args[0]=y,list[1].name=z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.String() != `{"args":["y","x"],"list":[null,{"name":"z"}],"spec":{"replicas":"3","template":{"image":"nginx"}}}` {
+		t.Error(n)
+	}
+}
+
+func TestFromFlatNestedDotted(t *testing.T) {
+	n, err := FromFlatString("a={b.c=1,d[0]=2}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.String() != `{"a":{"b":{"c":"1"},"d":["2"]}}` {
+		t.Error(n)
+	}
+}
+
+func TestFromFlatBadKeys(t *testing.T) {
+	for _, s := range []string{".a=1", "a..b=1", "a[=1", "a[x]=1", "a[-1]=1", "a[0]b=1", "=1"} {
+		if _, err := FromFlatString(s); err == nil {
+			t.Error(s)
+		}
+	}
+}
+
+func TestMergeFlatString(t *testing.T) {
+	n, _ := FromJSON([]byte(`{"spec":{"replicas":1,"name":"web"},"args":["a","b"],"x":"y"}`))
+	if err := MergeFlatString(n, "spec.replicas=3,args[1]=c,args[3]=e,x.z=1"); err != nil {
+		t.Fatal(err)
+	}
+	if n.String() != `{"args":["a","c",null,"e"],"spec":{"name":"web","replicas":"3"},"x":{"z":"1"}}` {
+		t.Error(n)
+	}
+	if err := MergeFlatString(NewArrayNode(), "a=1"); err == nil {
+		t.Error("merge into array should fail")
+	}
+}
+
+func TestFromFlatQuoted(t *testing.T) {
+	n, err := FromFlatString(`url="http://x?a=1,b={2}",q='it\'s',e="é\n",b=a\,b\=c,"x.y".z=1,a\.b=2,l=["]",'']`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := n.String(); s != `{"a.b":"2","b":"a,b=c","e":"é\n","l":["]",""],"q":"it's","url":"http://x?a=1,b={2}","x.y":{"z":"1"}}` {
+		t.Error(s)
+	}
+}
+
+func TestFromFlatEmpty(t *testing.T) {
+	n, err := FromFlatString("a=[],b={},c=,d=[,],e={x={}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := n.String(); s != `{"a":[],"b":{},"c":"","d":["",""],"e":{"x":{}}}` {
+		t.Error(s)
+	}
+	if n, err := FromFlatString(""); err != nil || n.Size() != 0 {
+		t.Error(n, err)
+	}
+}
+
+func TestFromFlatErrors(t *testing.T) {
+	tests := []struct {
+		s      string
+		offset int
+	}{
+		{"a", 1},
+		{"a=[x", 4},
+		{"a={b=1", 6},
+		{"a={b=1}x", 7},
+		{`a="xyz`, 2},
+		{`a="x"y`, 5},
+		{`a="\q"`, 4},
+		{`a='\n'`, 4},
+		{"a[1]b=2", 4},
+		{"a[99999999]=1", 2},
+		{"a.=1", 2},
+		{`a=x\`, 4},
+	}
+	for _, tc := range tests {
+		_, err := FromFlatString(tc.s)
+		se, ok := err.(*FlatSyntaxError)
+		if !ok {
+			t.Errorf("%s: %v", tc.s, err)
+			continue
+		}
+		if se.Offset != tc.offset {
+			t.Errorf("%s: %s (expected offset %d)", tc.s, se, tc.offset)
+		}
+	}
+}
+
+func TestFromFlatTyped(t *testing.T) {
+	n, err := FromFlatStringTyped(`a=null,b=true,c=false,d=3,e=-1.5e2,f=18446744073709551615,g=007,h="3",i=0x10,j=[1,x],k={l=2.5},m=,n=True`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := n.String(); s != `{"a":null,"b":true,"c":false,"d":3,"e":-150,"f":18446744073709551615,"g":"007","h":"3","i":"0x10","j":[1,"x"],"k":{"l":2.5},"m":"","n":"True"}` {
+		t.Error(s)
+	}
+	if n.Path("d").Unwrap() != int64(3) || n.Path("e").Unwrap() != float64(-150) {
+		t.Error(n.Path("d").Unwrap(), n.Path("e").Unwrap())
+	}
+}
+
+func TestFromFlatTypeHints(t *testing.T) {
+	n, err := FromFlatStringTyped(`port:int=8080,ratio:float=1,debug:bool=yes,version:str=1.10,ports:int=[80,"443"],x:null=,a.b[0]:bool=off,c:string=true`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := n.String(); s != `{"a":{"b":[false]},"c":"true","debug":true,"port":8080,"ports":[80,443],"ratio":1,"version":"1.10","x":null}` {
+		t.Error(s)
+	}
+	if n.Path("ratio").Unwrap() != float64(1) {
+		t.Error(n.Path("ratio").Unwrap())
+	}
+	n, err = FromFlatString("image:tag=latest")
+	if err != nil || n.Path("image:tag").AsText() != "latest" {
+		t.Error(n, err)
+	}
+}
+
+func TestFromFlatTypeHintErrors(t *testing.T) {
+	tests := []struct {
+		s      string
+		offset int
+	}{
+		{"port:int=http", 9},
+		{"a:bool=maybe", 7},
+		{"a:float=Inf", 8},
+		{"x:float=1e999", 8},
+		{"x:float=[1,-1e999]", 11},
+		{"a:list=1", 2},
+		{"a:int={b=1}", 6},
+		{"a:int.b=1", 5},
+		{"a:int=[1,x]", 9},
+	}
+	for _, tc := range tests {
+		_, err := FromFlatStringTyped(tc.s)
+		se, ok := err.(*FlatSyntaxError)
+		if !ok {
+			t.Errorf("%s: %v", tc.s, err)
+			continue
+		}
+		if se.Offset != tc.offset {
+			t.Errorf("%s: %s (expected offset %d)", tc.s, se, tc.offset)
+		}
+	}
+}
+
+func TestToFlatString(t *testing.T) {
+	n, _ := FromJSON([]byte(`{"b":[1,"2",true,null,[],{}],"a":{"x.y":"a,b","z":""},"c":"hello world","d":"it's","e":1.5}`))
+	s, err := n.ToFlatString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != `a={"x.y"="a,b",z=""},b=[1,"2",true,null,[],{}],c=hello world,d="it's",e=1.5` {
+		t.Error(s)
+	}
+	s, err = n.ToFlatStringDotted()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != `a."x.y"="a,b",a.z="",b[0]=1,b[1]="2",b[2]=true,b[3]=null,b[4]=[],b[5]={},c=hello world,d="it's",e=1.5` {
+		t.Error(s)
+	}
+	if _, err := NewArrayNode().ToFlatString(); err == nil {
+		t.Error("array should fail")
+	}
+	if s, _ := NewObjectNode().ToFlatStringDotted(); s != "" {
+		t.Error(s)
+	}
+	n = NewObjectNode().Put("f", 1.0).Put("u", uint64(math.MaxUint64)).Put("b", []byte("hi"))
+	s, _ = n.ToFlatString()
+	m, err := FromFlatStringTyped(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Path("f").Unwrap().(int64); !ok {
+		t.Error(m.Path("f"))
+	}
+	if _, ok := m.Path("u").Unwrap().(uint64); !ok {
+		t.Error(m.Path("u"))
+	}
+	if m.Path("b").GetType() != Text || m.Path("b").AsText() != n.Path("b").AsText() {
+		t.Error(m.Path("b"))
+	}
+}
+
+func TestFlatStringRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		n := randomFlatNode(r, 0, true)
+		for _, dotted := range []bool{false, true} {
+			s, err := n.toFlatString(dotted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := FromFlatStringTyped(s)
+			if err != nil {
+				t.Fatalf("%s: %v", s, err)
+			}
+			if m.String() != n.String() {
+				t.Fatalf("%s != %s (%s)", m, n, s)
+			}
+			checkFlatTypes(t, s, m, n)
+		}
+	}
+}
+
+// checkFlatTypes checks that m, read back from s, has the same types as
+// n, allowing for whole number floats being read back as int64.
+func checkFlatTypes(t *testing.T, s string, m, n *Node) {
+	t.Helper()
+	if m.GetType() != n.GetType() {
+		t.Fatalf("%s: %s is %s, not %s", s, m, m.GetType(), n.GetType())
+	}
+	switch n.GetType() {
+	case Object:
+		for k, v := range n.Fields() {
+			checkFlatTypes(t, s, m.Path(k), v)
+		}
+	case Array:
+		for i, v := range n.Items() {
+			checkFlatTypes(t, s, m.Get(i), v)
+		}
+	default:
+		want := fmt.Sprintf("%T", n.Unwrap())
+		if f, ok := n.Unwrap().(float64); ok && f == math.Trunc(f) {
+			want = "int64"
+		}
+		if got := fmt.Sprintf("%T", m.Unwrap()); got != want {
+			t.Fatalf("%s: %s is %s, not %s", s, m, got, want)
+		}
+	}
+}
+
+func randomFlatNode(r *rand.Rand, depth int, object bool) *Node {
+	k := r.Intn(9)
+	if object {
+		k = 7
+	}
+	if depth > 3 && k >= 6 {
+		k = r.Intn(6)
+	}
+	switch k {
+	case 0:
+		return NullNode
+	case 1:
+		return NewNode(r.Intn(2) == 0)
+	case 2:
+		return NewNode(r.Int63n(2000) - 1000)
+	case 3:
+		return NewNode(float64(r.Intn(2000)-1000) / 8)
+	case 4, 5:
+		return NewNode(randomFlatString(r))
+	case 6:
+		a := NewArrayNode()
+		for i := r.Intn(4); i > 0; i-- {
+			a.Append(randomFlatNode(r, depth+1, false))
+		}
+		return a
+	default:
+		o := NewObjectNode()
+		for i := r.Intn(4); i > 0; i-- {
+			o.Put(randomFlatString(r), randomFlatNode(r, depth+1, false))
+		}
+		return o
+	}
+}
+
+func randomFlatString(r *rand.Rand) string {
+	const chars = `ab1.-e[]{}=,:"'\ ` + "\n\té"
+	words := []string{"", "true", "null", "10", "1.5", "007"}
+	if r.Intn(4) == 0 {
+		return words[r.Intn(len(words))]
+	}
+	runes := []rune(chars)
+	var sb strings.Builder
+	for i := r.Intn(6); i > 0; i-- {
+		sb.WriteRune(runes[r.Intn(len(runes))])
+	}
+	return sb.String()
+}
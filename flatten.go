@@ -0,0 +1,338 @@
+package jnode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PathSyntax selects how paths are written by Flatten and read by
+// Unflatten and ParsePath.
+type PathSyntax int
+
+const (
+	// DottedPath writes paths like "a.b[0]" (see Path.Dotted.)
+	DottedPath PathSyntax = iota
+	// PointerPath writes paths as JSON Pointers like "/a/b/0".
+	PointerPath
+	// BracketPath writes paths like `["a"]["b"][0]` (see Path.Bracketed.)
+	BracketPath
+)
+
+// FlattenOptions control Flatten and Unflatten.
+type FlattenOptions struct {
+	Syntax PathSyntax
+}
+
+// Dotted returns the path in dotted form, e.g. "a.b[0].c", with field
+// names written as by ToFlatStringDotted (so a field name that is empty
+// or contains special characters is quoted, as in `a."b.c"`.)  The empty
+// path is "".
+func (p Path) Dotted() string {
+	var sb strings.Builder
+	for i, e := range p {
+		switch e := e.(type) {
+		case int:
+			sb.WriteByte('[')
+			sb.WriteString(strconv.Itoa(e))
+			sb.WriteByte(']')
+		default:
+			if i > 0 {
+				sb.WriteByte('.')
+			}
+			writeFlatField(&sb, e.(string))
+		}
+	}
+	return sb.String()
+}
+
+// Bracketed returns the path with each element in brackets, e.g.
+// `["a"][0]["b c"]`.  Field names are written as JSON strings.
+func (p Path) Bracketed() string {
+	var sb strings.Builder
+	for _, e := range p {
+		sb.WriteByte('[')
+		switch e := e.(type) {
+		case int:
+			sb.WriteString(strconv.Itoa(e))
+		default:
+			sb.Write(jsonString(e.(string)))
+		}
+		sb.WriteByte(']')
+	}
+	return sb.String()
+}
+
+// jsonString returns s as a JSON string, without escaping HTML characters.
+func jsonString(s string) []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'})
+}
+
+func (s PathSyntax) format(p Path) string {
+	switch s {
+	case PointerPath:
+		return p.Pointer()
+	case BracketPath:
+		return p.Bracketed()
+	default:
+		return p.Dotted()
+	}
+}
+
+// ParsePath parses a path written with the given syntax.  The elements
+// of a JSON Pointer are always strings, since a Pointer doesn't say
+// whether a token is a field name or an index.
+func ParsePath(s string, syntax PathSyntax) (Path, error) {
+	switch syntax {
+	case PointerPath:
+		tokens, err := parsePointer(s)
+		if err != nil {
+			return nil, err
+		}
+		p := make(Path, len(tokens))
+		for i, t := range tokens {
+			p[i] = t
+		}
+		return p, nil
+	case BracketPath:
+		return parseBracketPath(s)
+	default:
+		return parseDottedPath(s)
+	}
+}
+
+func parsePathIndex(s string, i int) (int, int, error) {
+	end := strings.IndexByte(s[i:], ']')
+	if end < 0 {
+		return 0, 0, fmt.Errorf("missing ']' in path %q", s)
+	}
+	digits := s[i : i+end]
+	n, err := strconv.Atoi(digits)
+	if err != nil || n < 0 || digits != strconv.Itoa(n) {
+		return 0, 0, fmt.Errorf("invalid index %q in path %q", digits, s)
+	}
+	return n, i + end + 1, nil
+}
+
+// parseDottedPath parses a path written by Path.Dotted, which uses the
+// field name syntax of FromFlatString (except that a path may start
+// with an index.)
+func parseDottedPath(s string) (Path, error) {
+	path := Path{}
+	p := &flatParser{s: s}
+	for !p.eof() {
+		if p.peek() == '[' {
+			n, next, err := parsePathIndex(s, p.pos+1)
+			if err != nil {
+				return nil, err
+			}
+			path, p.pos = append(path, n), next
+			continue
+		}
+		if len(path) > 0 {
+			if p.peek() != '.' {
+				return nil, fmt.Errorf("invalid path %q: %w", s, p.unexpected("'.' or '['"))
+			}
+			p.pos++
+		}
+		start := p.pos
+		field, err := p.parseField()
+		if err == nil && p.pos == start {
+			err = p.unexpected("field name")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid path %q: %w", s, err)
+		}
+		path = append(path, field)
+	}
+	return path, nil
+}
+
+func parseBracketPath(s string) (Path, error) {
+	p := Path{}
+	for i := 0; i < len(s); {
+		if s[i] != '[' || i+1 == len(s) {
+			return nil, fmt.Errorf("expected '[' at offset %d in path %q", i, s)
+		}
+		if s[i+1] != '"' {
+			n, next, err := parsePathIndex(s, i+1)
+			if err != nil {
+				return nil, err
+			}
+			p, i = append(p, n), next
+			continue
+		}
+		// find the closing quote
+		j := i + 2
+		for ; j < len(s) && s[j] != '"'; j++ {
+			if s[j] == '\\' {
+				j++
+			}
+		}
+		var name string
+		if j >= len(s) || json.Unmarshal([]byte(s[i+1:j+1]), &name) != nil {
+			return nil, fmt.Errorf("invalid field name at offset %d in path %q", i+1, s)
+		}
+		if j+1 == len(s) || s[j+1] != ']' {
+			return nil, fmt.Errorf("missing ']' in path %q", s)
+		}
+		p, i = append(p, name), j+2
+	}
+	return p, nil
+}
+
+// Flatten returns the leaves of n keyed by their paths.  The leaves
+// are scalars, and empty Objects and Arrays (so Unflatten can restore
+// them.)  A scalar n is returned with the empty path.  The Nodes in the
+// map are part of n.  JSON Pointer paths don't distinguish field names
+// from array indices, so with PointerPath an Object whose keys are
+// exactly "0", "1", ... "n" comes back from Unflatten as an Array; the
+// other syntaxes round trip exactly.
+func Flatten(n *Node, opts FlattenOptions) map[string]*Node {
+	m := make(map[string]*Node)
+	flatten(m, Path{}, n, opts.Syntax)
+	return m
+}
+
+func flatten(m map[string]*Node, path Path, n *Node, syntax PathSyntax) {
+	switch {
+	case n.IsObject() && n.Size() > 0:
+		for k, e := range n.Entries() {
+			flatten(m, path.Field(k), e, syntax)
+		}
+	case n.IsArray() && n.Size() > 0:
+		for i, e := range n.Elements() {
+			flatten(m, path.Index(i), e, syntax)
+		}
+	default:
+		m[syntax.format(path)] = n
+	}
+}
+
+// Unflatten rebuilds a Node from paths and values, as returned by
+// Flatten.  Missing array elements are filled with nulls, and an empty
+// map results in an empty Object.  A nil value is an error (use
+// NullNode for null.)  With JSON Pointer paths a container
+// becomes an Array if its keys are exactly "0", "1", ... "n", and an
+// Object otherwise.  The values are copied.
+func Unflatten(m map[string]*Node, opts FlattenOptions) (*Node, error) {
+	root := &flatTrie{}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if m[k] == nil {
+			return MissingNode, fmt.Errorf("%s: nil value", k)
+		}
+		path, err := ParsePath(k, opts.Syntax)
+		if err != nil {
+			return MissingNode, err
+		}
+		if err := root.insert(path, m[k], len(m)); err != nil {
+			return MissingNode, fmt.Errorf("%s: %w", k, err)
+		}
+	}
+	if root.leaf == nil && root.children == nil {
+		return NewObjectNode(), nil
+	}
+	v, err := root.value(opts.Syntax == PointerPath)
+	if err != nil {
+		return MissingNode, err
+	}
+	return &Node{v}, nil
+}
+
+type flatTrie struct {
+	leaf     *Node
+	children map[interface{}]*flatTrie
+}
+
+func (t *flatTrie) insert(path Path, leaf *Node, size int) error {
+	for _, e := range path {
+		if t.leaf != nil {
+			return fmt.Errorf("conflicts with a value at a shorter path")
+		}
+		if i, ok := e.(int); ok && i > max(size, maxFlatIndex) {
+			return fmt.Errorf("array index %d is too large", i)
+		}
+		if t.children == nil {
+			t.children = make(map[interface{}]*flatTrie)
+		}
+		c := t.children[e]
+		if c == nil {
+			c = &flatTrie{}
+			t.children[e] = c
+		}
+		t = c
+	}
+	if t.children != nil {
+		return fmt.Errorf("conflicts with values at longer paths")
+	}
+	t.leaf = leaf
+	return nil
+}
+
+func (t *flatTrie) value(pointer bool) (interface{}, error) {
+	if t.leaf != nil {
+		return copyValue(t.leaf.value), nil
+	}
+	ints, strs := 0, 0
+	for k := range t.children {
+		if _, ok := k.(int); ok {
+			ints++
+		} else {
+			strs++
+		}
+	}
+	if ints > 0 && strs > 0 {
+		return nil, fmt.Errorf("paths use both field names and indices for the same value")
+	}
+	if pointer {
+		// the keys are an array's indices if they are 0 ... n-1
+		isArray := true
+		for i := 0; i < len(t.children) && isArray; i++ {
+			_, isArray = t.children[strconv.Itoa(i)]
+		}
+		if isArray {
+			indexed := make(map[interface{}]*flatTrie, len(t.children))
+			for k, c := range t.children {
+				i, _ := strconv.Atoi(k.(string))
+				indexed[i] = c
+			}
+			t.children, ints, strs = indexed, len(indexed), 0
+		}
+	}
+	if strs > 0 {
+		m := make(map[string]interface{}, len(t.children))
+		for k, c := range t.children {
+			v, err := c.value(pointer)
+			if err != nil {
+				return nil, err
+			}
+			m[k.(string)] = v
+		}
+		return m, nil
+	}
+	size := 0
+	for k := range t.children {
+		size = max(size, k.(int)+1)
+	}
+	a := make([]interface{}, size)
+	for k, c := range t.children {
+		v, err := c.value(pointer)
+		if err != nil {
+			return nil, err
+		}
+		a[k.(int)] = v
+	}
+	return &a, nil
+}
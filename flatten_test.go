@@ -0,0 +1,139 @@
+package jnode
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func flatString(m map[string]*Node) string {
+	var lines []string
+	for k, v := range m {
+		lines = append(lines, k+" = "+v.String())
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+func TestFlatten(t *testing.T) {
+	n := mustJSON(t, `{"a": {"b.c": [1, {"d": null}], "e": {}}, "f": [], "": "x", "g\"": true}`)
+	tests := map[PathSyntax]string{
+		DottedPath: `"" = "x"
+"g\"" = true
+a."b.c"[0] = 1
+a."b.c"[1].d = null
+a.e = {}
+f = []`,
+		PointerPath: `/ = "x"
+/a/b.c/0 = 1
+/a/b.c/1/d = null
+/a/e = {}
+/f = []
+/g" = true`,
+		BracketPath: `[""] = "x"
+["a"]["b.c"][0] = 1
+["a"]["b.c"][1]["d"] = null
+["a"]["e"] = {}
+["f"] = []
+["g\""] = true`,
+	}
+	for syntax, expected := range tests {
+		m := Flatten(n, FlattenOptions{Syntax: syntax})
+		if s := flatString(m); s != expected {
+			t.Errorf("%d: %s", syntax, s)
+		}
+		u, err := Unflatten(m, FlattenOptions{Syntax: syntax})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.String() != n.String() {
+			t.Errorf("%d: %s", syntax, u)
+		}
+	}
+	if s := flatString(Flatten(NewNode(1), FlattenOptions{})); s != " = 1" {
+		t.Error(s)
+	}
+	if s := flatString(Flatten(mustJSON(t, `[[]]`), FlattenOptions{})); s != "[0] = []" {
+		t.Error(s)
+	}
+}
+
+func TestUnflatten(t *testing.T) {
+	m := map[string]*Node{"a[2]": NewNode(1), "b.c": NewNode("x"), "[weird": NewNode(0)}
+	if _, err := Unflatten(m, FlattenOptions{}); err == nil {
+		t.Error("expected an error")
+	}
+	delete(m, "[weird")
+	n, err := Unflatten(m, FlattenOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := n.String(); s != `{"a":[null,null,1],"b":{"c":"x"}}` {
+		t.Error(s)
+	}
+	n, err = Unflatten(map[string]*Node{"/a/0": NewNode(1), "/a/1": NewNode(2), "/b/1": NewNode(3)},
+		FlattenOptions{Syntax: PointerPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := n.String(); s != `{"a":[1,2],"b":{"1":3}}` {
+		t.Error(s)
+	}
+	obj := mustJSON(t, `{"0":"a","1":"b"}`)
+	if n, err := Unflatten(Flatten(obj, FlattenOptions{Syntax: PointerPath}), FlattenOptions{Syntax: PointerPath}); err != nil || n.String() != `["a","b"]` {
+		t.Error(n, err)
+	}
+	if n, err := Unflatten(Flatten(obj, FlattenOptions{}), FlattenOptions{}); err != nil || n.String() != `{"0":"a","1":"b"}` {
+		t.Error(n, err)
+	}
+	if n, err := Unflatten(map[string]*Node{}, FlattenOptions{}); err != nil || n.String() != "{}" {
+		t.Error(n, err)
+	}
+	errors := []map[string]*Node{
+		{"a": NewNode(1), "a.b": NewNode(2)},
+		{"a.b": NewNode(1), "a": NewNode(2)},
+		{"a[0]": NewNode(1), "a.b": NewNode(2)},
+		{"a[1000000]": NewNode(1)},
+		{"": NewNode(1), "a": NewNode(2)},
+		{"a": nil},
+		{"a[0]": nil},
+	}
+	for _, m := range errors {
+		if _, err := Unflatten(m, FlattenOptions{}); err == nil {
+			t.Errorf("%v: expected error", m)
+		}
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		s      string
+		syntax PathSyntax
+		path   string
+	}{
+		{"", DottedPath, "$"},
+		{"a.b[0]", DottedPath, "$.a.b[0]"},
+		{`a\.b.""[1][2]`, DottedPath, "$['a.b'][''][1][2]"},
+		{`"a.b".c`, DottedPath, "$['a.b'].c"},
+		{`'a=b'[0]."x[y]"`, DottedPath, "$['a=b'][0]['x[y]']"},
+		{"[3].x", DottedPath, "$[3].x"},
+		{`["a\"b"][0]`, BracketPath, `$['a"b'][0]`},
+		{"/a/0", PointerPath, "$.a['0']"},
+	}
+	for _, tc := range tests {
+		p, err := ParsePath(tc.s, tc.syntax)
+		if err != nil {
+			t.Errorf("%s: %v", tc.s, err)
+		} else if s := p.JSONPath(); s != tc.path {
+			t.Errorf("%s: %s", tc.s, s)
+		}
+	}
+	bad := map[string]PathSyntax{"a[": DottedPath, "a[01]": DottedPath, "a]": DottedPath, `a\`: DottedPath,
+		"a..b": DottedPath, `"a"b`: DottedPath, `"a`: DottedPath, "a=b": DottedPath,
+		"a": BracketPath, `["a"`: BracketPath, `[x]`: BracketPath, "b": PointerPath}
+	for s, syntax := range bad {
+		if _, err := ParsePath(s, syntax); err == nil {
+			t.Errorf("%s: expected error", s)
+		}
+	}
+}
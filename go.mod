@@ -1,5 +1,8 @@
 module github.com/soluble-ai/go-jnode
 
-go 1.13
+go 1.23
 
-require golang.org/x/tools v0.0.0-20191203134012-c197fd4bf371 // indirect
+require (
+	golang.org/x/tools v0.0.0-20191203134012-c197fd4bf371 // indirect
+	google.golang.org/protobuf v1.36.12
+)
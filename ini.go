@@ -0,0 +1,233 @@
+package jnode
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// INIOptions control FromINI.
+type INIOptions struct {
+	// Nested splits section names and keys on dots into nested
+	// Objects, so "[a.b]" becomes {"a":{"b":{...}}}.
+	Nested bool
+}
+
+// FromINI reads an INI file into an Object Node.  Keys before the first
+// section go in the top level Object and each "[section]" becomes a
+// sub-Object; a repeated section adds to the earlier one.  Keys and
+// values are separated by '=' or ':', lines starting with ';' or '#'
+// are comments, and a line ending with a backslash continues on the
+// next line.  Values are Text, with surrounding whitespace trimmed,
+// and a value in double quotes is unquoted with Go escapes.  A key
+// ending with "[]" appends its value to an Array (and it is an error
+// if the key already has a value that isn't an Array.)  When a key is
+// otherwise repeated, the last value wins.
+func FromINI(data []byte, opts INIOptions) (*Node, error) {
+	root := make(map[string]interface{})
+	var section []string
+	lines := splitLines(string(data))
+	for i := 0; i < len(lines); i++ {
+		number := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+		for continues(line) {
+			line = line[:len(line)-1]
+			if i+1 < len(lines) {
+				i++
+				line += strings.TrimSpace(lines[i])
+			}
+		}
+		fail := func(format string, args ...interface{}) (*Node, error) {
+			return MissingNode, fmt.Errorf("ini: line %d: %s", number, fmt.Sprintf(format, args...))
+		}
+		if line[0] == '[' {
+			if line[len(line)-1] != ']' {
+				return fail("expected ] at end of section")
+			}
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if name == "" {
+				return fail("empty section name")
+			}
+			section = splitINIKey(name, opts.Nested)
+			if _, err := iniObject(root, section); err != nil {
+				return fail("%v", err)
+			}
+			continue
+		}
+		sep := strings.IndexAny(line, "=:")
+		if sep <= 0 {
+			return fail("expected key = value")
+		}
+		key := strings.TrimSpace(line[:sep])
+		value, err := iniValue(strings.TrimSpace(line[sep+1:]))
+		if err != nil {
+			return fail("%v", err)
+		}
+		k := strings.TrimSuffix(key, "[]")
+		keys := append(section[:len(section):len(section)], splitINIKey(strings.TrimSpace(k), opts.Nested)...)
+		if k != key {
+			parent, err := iniObject(root, keys[:len(keys)-1])
+			if err != nil {
+				return fail("%v", err)
+			}
+			var a *[]interface{}
+			switch existing := parent[keys[len(keys)-1]].(type) {
+			case nil:
+				a = &[]interface{}{}
+			case *[]interface{}:
+				a = existing
+			default:
+				return fail("%s is not an array", strings.Join(keys, "."))
+			}
+			*a = append(*a, value)
+			err = putDotted(root, keys, a)
+		} else {
+			err = putDotted(root, keys, value)
+		}
+		if err != nil {
+			return fail("%v", err)
+		}
+	}
+	return &Node{root}, nil
+}
+
+func splitINIKey(key string, nested bool) []string {
+	if !nested {
+		return []string{key}
+	}
+	return strings.Split(key, ".")
+}
+
+// iniObject returns the Object at keys within m, creating it if needed.
+func iniObject(m map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	for i, k := range keys {
+		switch sub := m[k].(type) {
+		case nil:
+			next := make(map[string]interface{})
+			m[k] = next
+			m = next
+		case map[string]interface{}:
+			m = sub
+		default:
+			return nil, fmt.Errorf("%s is not an object", strings.Join(keys[:i+1], "."))
+		}
+	}
+	return m, nil
+}
+
+func iniValue(s string) (string, error) {
+	if s == "" || s[0] != '"' {
+		return s, nil
+	}
+	v, err := strconv.Unquote(s)
+	if err != nil {
+		return "", fmt.Errorf("malformed quoted value %s", s)
+	}
+	return v, nil
+}
+
+// ToINI writes an Object Node as an INI file.  Scalars and Arrays of
+// scalars in the top level Object are written first, then each nested
+// Object as a section, with deeper Objects as dotted sections such as
+// "[a.b]" that FromINI reads back with Nested set.  Arrays are written
+// as repeated "key[] = value" lines, and values that would not read
+// back unchanged are double quoted.  Nulls, empty Arrays, Arrays of
+// containers, keys that can't be parsed back, and keys or sections
+// with the same dotted name (e.g. {"a.b": {}, "a": {"b": {}}}) are
+// errors.
+func (n *Node) ToINI() ([]byte, error) {
+	m, ok := n.value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("ini: not an object")
+	}
+	var sb strings.Builder
+	if err := writeINISection(&sb, "", m, make(map[string]bool)); err != nil {
+		return nil, err
+	}
+	return []byte(sb.String()), nil
+}
+
+// writeINISection writes a section, recording the dotted names of the
+// section and its keys in written.
+func writeINISection(sb *strings.Builder, name string, m map[string]interface{}, written map[string]bool) error {
+	if name != "" {
+		if strings.ContainsAny(name, "]\r\n") || strings.TrimSpace(name) != name {
+			return fmt.Errorf("ini: section %q can't be represented", name)
+		}
+		if written[name] {
+			return fmt.Errorf("ini: %s is written more than once", name)
+		}
+		written[name] = true
+		if sb.Len() > 0 {
+			sb.WriteByte('\n')
+		}
+		fmt.Fprintf(sb, "[%s]\n", name)
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var sections []string
+	for _, k := range keys {
+		path := k
+		if name != "" {
+			path = name + "." + k
+		}
+		if _, ok := m[k].(map[string]interface{}); ok && k != "" {
+			sections = append(sections, k)
+			continue
+		}
+		if k == "" || strings.ContainsAny(k, "=:\r\n") || strings.TrimSpace(k) != k ||
+			k[0] == '[' || k[0] == ';' || k[0] == '#' || strings.HasSuffix(k, "[]") {
+			return fmt.Errorf("ini: key %q can't be represented", path)
+		}
+		if written[path] {
+			return fmt.Errorf("ini: %s is written more than once", path)
+		}
+		written[path] = true
+		switch v := m[k].(type) {
+		case map[string]interface{}:
+			return fmt.Errorf("ini: section %q can't be represented", path)
+		case nil:
+			return fmt.Errorf("ini: %s is null, which can't be represented", path)
+		case *[]interface{}:
+			if len(*v) == 0 {
+				return fmt.Errorf("ini: %s is an empty array, which can't be represented", path)
+			}
+			for _, e := range *v {
+				switch e.(type) {
+				case nil, map[string]interface{}, *[]interface{}:
+					return fmt.Errorf("ini: %s contains %s, which can't be represented",
+						path, strings.ToLower((&Node{e}).GetType().String()))
+				}
+				fmt.Fprintf(sb, "%s[] = %s\n", k, iniQuote((&Node{e}).AsText()))
+			}
+		default:
+			fmt.Fprintf(sb, "%s = %s\n", k, iniQuote((&Node{v}).AsText()))
+		}
+	}
+	for _, k := range sections {
+		path := k
+		if name != "" {
+			path = name + "." + k
+		}
+		if err := writeINISection(sb, path, m[k].(map[string]interface{}), written); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// iniQuote quotes s if FromINI would not read it back unchanged.
+func iniQuote(s string) string {
+	if s == "" || strings.TrimSpace(s) != s || s[0] == '"' || strings.HasSuffix(s, `\`) ||
+		strings.ContainsAny(s, "\r\n") {
+		return strconv.Quote(s)
+	}
+	return s
+}
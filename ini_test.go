@@ -0,0 +1,115 @@
+package jnode
+
+import (
+	"strings"
+	"testing"
+)
+
+const iniExample = `; top level
+name = example
+version: 2
+
+[database]
+host = db.example.com  
+port = 5432
+password = "  spaced \"secret\"  "
+query = SELECT * \
+        FROM t
+
+# the servers
+[servers.alpha]
+ip = 10.0.0.1
+tags[] = web
+tags[] = frontend
+
+[database]
+user = admin
+`
+
+func TestFromINI(t *testing.T) {
+	n, err := FromINI([]byte(iniExample), INIOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"database":{"host":"db.example.com","password":"  spaced \"secret\"  ","port":"5432","query":"SELECT * FROM t","user":"admin"},` +
+		`"name":"example","servers.alpha":{"ip":"10.0.0.1","tags":["web","frontend"]},"version":"2"}`
+	if s := n.String(); s != want {
+		t.Error(s)
+	}
+	n, err = FromINI([]byte(iniExample), INIOptions{Nested: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := n.Path("servers").String(); s != `{"alpha":{"ip":"10.0.0.1","tags":["web","frontend"]}}` {
+		t.Error(s)
+	}
+	n, err = FromINI([]byte("[a]\nb.c = 1\nb.d[] = 2"), INIOptions{Nested: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := n.String(); s != `{"a":{"b":{"c":"1","d":["2"]}}}` {
+		t.Error(s)
+	}
+}
+
+func TestFromINIErrors(t *testing.T) {
+	tests := map[string]string{
+		"[a":                    "line 1: expected ]",
+		"[ ]":                   "line 1: empty section",
+		"a = 1\nnovalue":        "line 2: expected key = value",
+		"= 1":                   "line 1: expected key = value",
+		"a = \"x":               "line 1: malformed quoted value",
+		"a = 1\n[a]":            "line 2: a is not an object",
+		"[a]\nb = 1\n[a.b.c]":   "line 3: a.b is not an object",
+		"[a.b]\n[a]\nb = 1":     "line 3: a.b is an object",
+		"x = 1\nx[] = 2":        "line 2: x is not an array",
+		"[s]\nx.y = 1\nx[] = 2": "line 3: s.x is not an array",
+	}
+	for in, want := range tests {
+		_, err := FromINI([]byte(in), INIOptions{Nested: true})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: %v", in, err)
+		}
+	}
+}
+
+func TestToINI(t *testing.T) {
+	n, _ := FromJSON([]byte(`{"name":"example","list":[1,"two",true],"empty":"",` +
+		`"database":{"host":"db","port":5432,"note":" padded "},"servers":{"alpha":{"ip":"10.0.0.1"},"beta":{}}}`))
+	b, err := n.ToINI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "empty = \"\"\n" +
+		"list[] = 1\n" +
+		"list[] = two\n" +
+		"list[] = true\n" +
+		"name = example\n" +
+		"\n[database]\n" +
+		"host = db\n" +
+		"note = \" padded \"\n" +
+		"port = 5432\n" +
+		"\n[servers]\n" +
+		"\n[servers.alpha]\n" +
+		"ip = 10.0.0.1\n" +
+		"\n[servers.beta]\n"
+	if string(b) != want {
+		t.Error(string(b))
+	}
+	back, err := FromINI(b, INIOptions{Nested: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := back.String(); s != `{"database":{"host":"db","note":" padded ","port":"5432"},"empty":"",`+
+		`"list":["1","two","true"],"name":"example","servers":{"alpha":{"ip":"10.0.0.1"},"beta":{}}}` {
+		t.Error(s)
+	}
+	for _, in := range []string{`[]`, `{"a":null}`, `{"a":[]}`, `{"s":{"a":[]}}`, `{"a":[[1]]}`, `{"a":[{}]}`, `{"a=b":1}`,
+		`{" a":1}`, `{"a[]":1}`, `{"s":{"x]":{}}}`, `{"":{}}`, `{"a.b":{"c":"1"},"a":{"b":{"c":"2"}}}`,
+		`{"a.b":"1","a":{"b":{}}}`, `{"a":{"b.c":"1","b":{"c":"2"}}}`} {
+		n, _ := FromJSON([]byte(in))
+		if _, err := n.ToINI(); err == nil {
+			t.Error(in)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package jnode
+
+import (
+	"iter"
+)
+
+// Fields returns an iterator over the fields of an Object Node,
+// in no particular order.  Unlike Entries it doesn't build a map.
+// The iterator is empty if the Node is not an Object.
+func (n *Node) Fields() iter.Seq2[string, *Node] {
+	return func(yield func(string, *Node) bool) {
+		if !n.IsObject() {
+			return
+		}
+		for k, v := range n.ToMap() {
+			if !yield(k, &Node{v}) {
+				return
+			}
+		}
+	}
+}
+
+// SortedFields is like Fields but returns the fields in sorted
+// order, for deterministic output.
+func (n *Node) SortedFields() iter.Seq2[string, *Node] {
+	return func(yield func(string, *Node) bool) {
+		if !n.IsObject() {
+			return
+		}
+		m := n.ToMap()
+		for _, k := range sortedKeys(m) {
+			if !yield(k, &Node{m[k]}) {
+				return
+			}
+		}
+	}
+}
+
+// Items returns an iterator over the index and value of each element
+// of an Array Node.  Unlike Elements it doesn't build a slice.  The
+// iterator is empty if the Node is not an Array.
+func (n *Node) Items() iter.Seq2[int, *Node] {
+	return func(yield func(int, *Node) bool) {
+		if !n.IsArray() {
+			return
+		}
+		for i, v := range *n.toSlicePtr() {
+			if !yield(i, &Node{v}) {
+				return
+			}
+		}
+	}
+}
+
+// All returns an iterator over the Node and all of its descendants,
+// along with their paths, in pre-order like Walk but with Object
+// fields in no particular order.  Unlike Walk it doesn't build a map
+// or slice for each container.
+func (n *Node) All() iter.Seq2[Path, *Node] {
+	return func(yield func(Path, *Node) bool) {
+		all(n, Path{}, false, yield)
+	}
+}
+
+// SortedAll is like All but visits Object fields in sorted order,
+// the same order as Walk.
+func (n *Node) SortedAll() iter.Seq2[Path, *Node] {
+	return func(yield func(Path, *Node) bool) {
+		all(n, Path{}, true, yield)
+	}
+}
+
+// all returns false if yield stopped the iteration.
+func all(n *Node, path Path, sorted bool, yield func(Path, *Node) bool) bool {
+	if !yield(path, n) {
+		return false
+	}
+	switch v := n.value.(type) {
+	case map[string]interface{}:
+		if sorted {
+			for _, k := range sortedKeys(v) {
+				if !all(&Node{v[k]}, path.Field(k), sorted, yield) {
+					return false
+				}
+			}
+			return true
+		}
+		for k, e := range v {
+			if !all(&Node{e}, path.Field(k), sorted, yield) {
+				return false
+			}
+		}
+	case *[]interface{}:
+		for i, e := range *v {
+			if !all(&Node{e}, path.Index(i), sorted, yield) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package jnode
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestFields(t *testing.T) {
+	n := mustJSON(t, `{"b":2,"a":1,"c":3}`)
+	sum := 0
+	for k, v := range n.Fields() {
+		if n.Path(k).AsInt() != v.AsInt() {
+			t.Error(k, v)
+		}
+		sum += v.AsInt()
+	}
+	if sum != 6 {
+		t.Error(sum)
+	}
+	var keys []string
+	for k := range n.SortedFields() {
+		keys = append(keys, k)
+		if k == "b" {
+			break
+		}
+	}
+	if strings.Join(keys, ",") != "a,b" {
+		t.Error(keys)
+	}
+	for range NewArrayNode().Append(1).Fields() {
+		t.Error("array has no fields")
+	}
+	for range NewArrayNode().Append(1).SortedFields() {
+		t.Error("array has no fields")
+	}
+}
+
+func TestItems(t *testing.T) {
+	n := NewArrayNode().Append([]string{"x", "y", "z"})
+	var s []string
+	for i, v := range n.Items() {
+		s = append(s, v.AsText())
+		if i == 1 {
+			break
+		}
+	}
+	if strings.Join(s, ",") != "x,y" {
+		t.Error(s)
+	}
+	for range NewObjectNode().Put("a", 1).Items() {
+		t.Error("object has no items")
+	}
+}
+
+func TestAll(t *testing.T) {
+	n := mustJSON(t, `{"b":[1,2],"a":{"x":true}}`)
+	var paths []string
+	for p, v := range n.SortedAll() {
+		paths = append(paths, p.Pointer())
+		if v.IsNumber() {
+			break
+		}
+	}
+	if s := strings.Join(paths, " "); s != " /a /a/x /b /b/0" {
+		t.Error(s)
+	}
+	paths = nil
+	for p, v := range n.All() {
+		if v.String() != p.Find(n).String() {
+			t.Error(p, v)
+		}
+		paths = append(paths, p.Pointer())
+	}
+	sort.Strings(paths)
+	if s := strings.Join(paths, " "); s != " /a /a/x /b /b/0 /b/1" {
+		t.Error(s)
+	}
+	allocs := testing.AllocsPerRun(10, func() {
+		for range n.All() {
+		}
+	})
+	walkAllocs := testing.AllocsPerRun(10, func() {
+		Walk(n, func(Path, *Node) WalkAction { return WalkContinue })
+	})
+	if allocs >= walkAllocs {
+		t.Error(allocs, walkAllocs)
+	}
+}
@@ -15,9 +15,9 @@
 package jnode
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
@@ -129,6 +129,11 @@ func unpointSlices(value interface{}) interface{} {
 
 func pointSlices(value interface{}) interface{} {
 	switch v := value.(type) {
+	case *[]interface{}:
+		for i, e := range *v {
+			(*v)[i] = pointSlices(e)
+		}
+		return v
 	case []interface{}:
 		for i, e := range v {
 			v[i] = pointSlices(e)
@@ -144,7 +149,8 @@ func pointSlices(value interface{}) interface{} {
 	}
 }
 
-// MarshalJSON is the custom JSON marshaller for a Node
+// MarshalJSON is the custom JSON marshaller for a Node.  Binary
+// values are encoded as standard base64 (see BinaryCodec.)
 func (n *Node) MarshalJSON() ([]byte, error) {
 	u := unpointSlices(n.value)
 	defer pointSlices(n.value)
@@ -242,11 +248,92 @@ func (n *Node) Size() int {
 	}
 }
 
-// AsText returns the text of a Node
+// AsText returns the text of a Node.  Text is returned as is, numbers
+// are formatted in their shortest form that round trips (as JSON does),
+// bools are "true" or "false", Binary values are encoded as base64,
+// Objects and Arrays are formatted as JSON, and null and missing Nodes
+// are "".  (See AsLegacyText.)
 func (n *Node) AsText() string {
+	switch v := n.value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case int8:
+		return strconv.FormatInt(int64(v), 10)
+	case int16:
+		return strconv.FormatInt(int64(v), 10)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint8:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint16:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float32:
+		return formatFloat(float64(v), 32)
+	case float64:
+		return formatFloat(v, 64)
+	case json.Number:
+		return v.String()
+	case []byte:
+		return encodeBinary(v)
+	default:
+		return n.String()
+	}
+}
+
+// AsLegacyText returns the text of a Node the way AsText originally
+// did, by formatting the value with fmt's %v verb (so an Object is
+// "map[...]" and a null is "<nil>".)
+func (n *Node) AsLegacyText() string {
 	return fmt.Sprintf("%v", n.value)
 }
 
+// AsTextOr returns AsText, or def if the Node is null or missing.
+func (n *Node) AsTextOr(def string) string {
+	if n.IsNull() || n.IsMissing() {
+		return def
+	}
+	return n.AsText()
+}
+
+// formatFloat formats a float the way encoding/json does, or as
+// "NaN", "+Inf" or "-Inf" for values JSON can't represent.
+func formatFloat(f float64, bits int) string {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return strconv.FormatFloat(f, 'g', -1, bits)
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) ||
+			bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	b := strconv.AppendFloat(nil, f, format, -1, bits)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return string(b)
+}
+
 // AsInt returns the value of a Node as an int.  For a String,
 // returns the value of strconv.Atoi or 0.  For bool returns 0 or 1.
 // Otherwise returns 0.
@@ -360,17 +447,10 @@ func (n *Node) AsBool() bool {
 }
 
 // AsBinary returns the binary value of a Node.  If
-// the Node is string, it attempts to base64 decode it.
+// the Node is string, it attempts to decode it as standard
+// base64, with or without padding (see BinaryCodec.)
 func (n *Node) AsBinary() ([]byte, error) {
-	switch n.GetType() {
-	case Binary:
-		return n.value.([]byte), nil
-	case Text:
-		s := n.value.(string)
-		return base64.RawStdEncoding.DecodeString(s)
-	default:
-		return nil, fmt.Errorf("Node is not binary")
-	}
+	return BinaryCodec{}.AsBinary(n)
 }
 
 func denode(value interface{}) (interface{}, error) {
@@ -378,7 +458,7 @@ func denode(value interface{}) (interface{}, error) {
 	case *Node:
 		return v.value, nil
 	case int, int8, int16, int32, int64, float32, float64, string, bool,
-		uint, uint8, uint16, uint32, uint64:
+		uint, uint8, uint16, uint32, uint64, []byte:
 		return v, nil
 	case []interface{}, map[string]interface{}:
 		return pointSlices(value), nil
@@ -389,6 +469,26 @@ func denode(value interface{}) (interface{}, error) {
 	}
 }
 
+// copyValue returns a deep copy of a generic value.
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *[]interface{}:
+		a := make([]interface{}, len(*v))
+		for i, e := range *v {
+			a[i] = copyValue(e)
+		}
+		return &a
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = copyValue(e)
+		}
+		return m
+	default:
+		return v
+	}
+}
+
 // Put sets the value of a field in an Object Node.  Returns
 // the Node (for chaining).  Panics if the Node is not an Object.
 func (n *Node) Put(name string, value interface{}) *Node {
@@ -17,6 +17,7 @@ package jnode
 import (
 	"bytes"
 	"encoding/json"
+	"math"
 	"strings"
 	"testing"
 )
@@ -389,3 +390,56 @@ func TestJSONNumber(t *testing.T) {
 		t.Error(f)
 	}
 }
+
+func TestAsText(t *testing.T) {
+	tests := []struct {
+		n    *Node
+		text string
+	}{
+		{NewNode("hello"), "hello"},
+		{NewNode(true), "true"},
+		{NewNode(int8(-5)), "-5"},
+		{NewNode(uint64(18446744073709551615)), "18446744073709551615"},
+		{NewNode(0.1), "0.1"},
+		{NewNode(float32(0.1)), "0.1"},
+		{NewNode(100.0), "100"},
+		{NewNode(1e21), "1e+21"},
+		{NewNode(1e-7), "1e-7"},
+		{NewNode(math.Inf(-1)), "-Inf"},
+		{&Node{json.Number("1.50")}, "1.50"},
+		{NewNode([]byte("3.141")), "My4xNDE="},
+		{NewObjectNode().Put("a", 1), `{"a":1}`},
+		{NewArrayNode().Append(1).Append(2), "[1,2]"},
+		{NullNode, ""},
+		{MissingNode, ""},
+	}
+	for _, tc := range tests {
+		if s := tc.n.AsText(); s != tc.text {
+			t.Errorf("%s != %s", s, tc.text)
+		}
+	}
+	if s := NullNode.AsTextOr("x"); s != "x" {
+		t.Error(s)
+	}
+	if s := NewObjectNode().Path("a").AsTextOr("x"); s != "x" {
+		t.Error(s)
+	}
+	if s := NewNode("").AsTextOr("x"); s != "" {
+		t.Error(s)
+	}
+	if s := NullNode.AsLegacyText(); s != "<nil>" {
+		t.Error(s)
+	}
+	if s := NewObjectNode().Put("a", 1).AsLegacyText(); s != "map[a:1]" {
+		t.Error(s)
+	}
+}
+
+func TestMarshalRootArray(t *testing.T) {
+	a := NewArrayNode().Append(NewNode([]byte{1}))
+	a.AppendObject().PutArray("a").Append(1)
+	_ = a.String()
+	if a.Get(0).GetType() != Binary || a.Get(1).Path("a").GetType() != Array {
+		t.Error(a.Get(0).GetType(), a.Get(1).Path("a").GetType())
+	}
+}
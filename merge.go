@@ -0,0 +1,237 @@
+package jnode
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// ArrayStrategy says how Merge combines two arrays.
+type ArrayStrategy int
+
+const (
+	// ArrayReplace replaces the destination array with the source array.
+	ArrayReplace ArrayStrategy = iota
+	// ArrayConcat appends the source elements to the destination array.
+	ArrayConcat
+	// ArrayUnion appends the source elements that aren't already in the
+	// destination array.  If a union key is given, elements are objects
+	// identified by the value of the key field, and matching elements
+	// are merged.  Otherwise elements are compared by value.
+	ArrayUnion
+)
+
+// MergeRule controls how Merge combines the values at a path.
+type MergeRule struct {
+	// Arrays is the strategy for arrays.
+	Arrays ArrayStrategy
+	// UnionKey is the field that identifies array elements for ArrayUnion.
+	UnionKey string
+	// Replace replaces the destination value with the source value,
+	// even if both are objects.
+	Replace bool
+}
+
+// MergeOptions controls Merge.
+type MergeOptions struct {
+	// Arrays is the default strategy for arrays.
+	Arrays ArrayStrategy
+	// UnionKey is the default field that identifies array elements
+	// for ArrayUnion.
+	UnionKey string
+	// ErrorOnConflict makes Merge return an error (without modifying
+	// the destination) when the types of two values conflict.  Otherwise
+	// the source value wins.
+	ErrorOnConflict bool
+	// Paths overrides the default rule for the values at particular
+	// paths.  The keys are JSON Pointers in which a "*" token matches any
+	// field or array index, e.g. "/spec/containers/*/ports".  When more
+	// than one pattern matches a path, the most specific one is used:
+	// patterns are compared token by token, and the first difference
+	// between a field and "*" favors the field, so for "/a/b" the pattern
+	// "/a/b" beats "/a/*", which beats "/*/b".
+	Paths map[string]MergeRule
+}
+
+// MergeConflict records a pair of values of different types found
+// by Merge.
+type MergeConflict struct {
+	// Path is the JSON Pointer to the value in the destination.
+	Path string
+	Dst  *Node
+	Src  *Node
+}
+
+func (c MergeConflict) String() string {
+	return fmt.Sprintf("%s: %s value %s conflicts with %s value %s", c.Path,
+		c.Dst.GetType(), c.Dst, c.Src.GetType(), c.Src)
+}
+
+// Merge deep merges src into dst.  Objects are merged field by field,
+// arrays according to the options, and any other source value replaces
+// the destination value.  A null in either dst or src never conflicts.
+// The merged values are copied from src, so src can be reused.
+//
+// Merge returns the type conflicts it found (resolved in favor of src
+// unless opts.ErrorOnConflict is set.)  dst must be an Object or Array,
+// and is updated in place, unless src has a different type in which case
+// dst becomes a copy of src.
+func Merge(dst, src *Node, opts MergeOptions) ([]MergeConflict, error) {
+	if !dst.IsContainer() {
+		return nil, fmt.Errorf("cannot merge into %s node", dst.GetType())
+	}
+	rules := make([]mergePathRule, 0, len(opts.Paths))
+	for p, r := range opts.Paths {
+		tokens, err := parsePointer(p)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, mergePathRule{p, tokens, r})
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].moreSpecific(rules[j])
+	})
+	m := &merger{
+		def:   MergeRule{Arrays: opts.Arrays, UnionKey: opts.UnionKey},
+		rules: rules,
+	}
+	if opts.ErrorOnConflict {
+		m.merge(copyValue(dst.value), src.value, nil)
+		if len(m.conflicts) > 0 {
+			return m.conflicts, fmt.Errorf("merge conflict at %s (%d total)",
+				m.conflicts[0].Path, len(m.conflicts))
+		}
+	}
+	dst.value = m.merge(dst.value, src.value, nil)
+	return m.conflicts, nil
+}
+
+type mergePathRule struct {
+	pattern string
+	tokens  []string
+	rule    MergeRule
+}
+
+// moreSpecific orders rules so that the first one that matches
+// a path is the most specific.
+func (r mergePathRule) moreSpecific(o mergePathRule) bool {
+	if len(r.tokens) != len(o.tokens) {
+		return len(r.tokens) > len(o.tokens)
+	}
+	for i, t := range r.tokens {
+		if wr, wo := t == "*", o.tokens[i] == "*"; wr != wo {
+			return wo
+		}
+	}
+	return r.pattern < o.pattern
+}
+
+type merger struct {
+	def       MergeRule
+	rules     []mergePathRule
+	conflicts []MergeConflict
+}
+
+func (m *merger) rule(path []string) MergeRule {
+	for _, r := range m.rules {
+		if len(r.tokens) != len(path) {
+			continue
+		}
+		match := true
+		for i, t := range r.tokens {
+			if t != "*" && t != path[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return r.rule
+		}
+	}
+	return m.def
+}
+
+// merge merges src into dst, modifying dst if possible, and returns
+// the merged value.
+func (m *merger) merge(dst, src interface{}, path []string) interface{} {
+	d, s := &Node{dst}, &Node{src}
+	rule := m.rule(path)
+	dt, st := d.GetType(), s.GetType()
+	switch {
+	case rule.Replace:
+	case dt == Object && st == Object:
+		dm, sm := d.ToMap(), s.ToMap()
+		for _, k := range sortedKeys(sm) {
+			v := sm[k]
+			if dv, ok := dm[k]; ok {
+				dm[k] = m.merge(dv, v, appendPath(path, k))
+			} else {
+				dm[k] = copyValue(v)
+			}
+		}
+		return dst
+	case dt == Array && st == Array:
+		return m.mergeArrays(d, s, rule, path)
+	case dt != st && dt != Null && st != Null:
+		m.conflicts = append(m.conflicts, MergeConflict{
+			Path: formatPointer(path),
+			Dst:  &Node{copyValue(dst)},
+			Src:  s,
+		})
+	}
+	return copyValue(src)
+}
+
+func (m *merger) mergeArrays(d, s *Node, rule MergeRule, path []string) interface{} {
+	if rule.Arrays == ArrayReplace {
+		return copyValue(s.value)
+	}
+	a := d.toSlicePtr()
+	for _, v := range *s.toSlicePtr() {
+		if rule.Arrays == ArrayUnion {
+			if i := unionIndex(*a, v, rule.UnionKey); i >= 0 {
+				if rule.UnionKey != "" {
+					(*a)[i] = m.merge((*a)[i], v, appendPath(path, strconv.Itoa(i)))
+				}
+				continue
+			}
+		}
+		*a = append(*a, copyValue(v))
+	}
+	return d.value
+}
+
+// unionIndex returns the index of the element of a that matches v,
+// or -1.
+func unionIndex(a []interface{}, v interface{}, key string) int {
+	var id string
+	if key != "" {
+		k := (&Node{v}).Path(key)
+		if k.IsMissing() {
+			return -1
+		}
+		id = k.String()
+	} else {
+		id = (&Node{v}).String()
+	}
+	for i, e := range a {
+		n := &Node{e}
+		if key != "" {
+			n = n.Path(key)
+			if n.IsMissing() {
+				continue
+			}
+		}
+		if n.String() == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func appendPath(path []string, token string) []string {
+	p := make([]string, len(path)+1)
+	copy(p, path)
+	p[len(path)] = token
+	return p
+}
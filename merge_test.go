@@ -0,0 +1,111 @@
+package jnode
+
+import (
+	"testing"
+)
+
+func mustJSON(t *testing.T, s string) *Node {
+	t.Helper()
+	n, err := FromJSON([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		dst, src string
+		opts     MergeOptions
+		result   string
+	}{
+		{`{"a":1,"b":{"c":2,"d":[1,2]}}`, `{"b":{"c":3,"d":[3]},"e":null}`, MergeOptions{},
+			`{"a":1,"b":{"c":3,"d":[3]},"e":null}`},
+		{`{"d":[1,2]}`, `{"d":[2,3]}`, MergeOptions{Arrays: ArrayConcat},
+			`{"d":[1,2,2,3]}`},
+		{`{"d":[1,2]}`, `{"d":[2,3]}`, MergeOptions{Arrays: ArrayUnion},
+			`{"d":[1,2,3]}`},
+		{`{"c":[{"name":"a","x":1},{"name":"b"}]}`, `{"c":[{"name":"a","y":2},{"name":"c"},{"z":1}]}`,
+			MergeOptions{Arrays: ArrayUnion, UnionKey: "name"},
+			`{"c":[{"name":"a","x":1,"y":2},{"name":"b"},{"name":"c"},{"z":1}]}`},
+		{`{"spec":{"containers":[{"name":"a","ports":[80]}],"args":[1]}}`,
+			`{"spec":{"containers":[{"name":"a","ports":[443]}],"args":[2]}}`,
+			MergeOptions{Paths: map[string]MergeRule{
+				"/spec/containers":         {Arrays: ArrayUnion, UnionKey: "name"},
+				"/spec/containers/*/ports": {Arrays: ArrayConcat},
+			}},
+			`{"spec":{"args":[2],"containers":[{"name":"a","ports":[80,443]}]}}`},
+		{`{"a":{"b":1},"c":{"d":1}}`, `{"a":{"c":2},"c":{"e":2}}`,
+			MergeOptions{Paths: map[string]MergeRule{"/a": {Replace: true}}},
+			`{"a":{"c":2},"c":{"d":1,"e":2}}`},
+		{`[1]`, `[2]`, MergeOptions{Arrays: ArrayConcat}, `[1,2]`},
+	}
+	for _, tc := range tests {
+		dst, src := mustJSON(t, tc.dst), mustJSON(t, tc.src)
+		conflicts, err := Merge(dst, src, tc.opts)
+		if err != nil || len(conflicts) != 0 {
+			t.Error(err, conflicts)
+		}
+		if s := dst.String(); s != tc.result {
+			t.Errorf("%s + %s = %s", tc.dst, tc.src, s)
+		}
+		// src must not be shared with dst
+		if src.IsObject() {
+			for k := range src.ToMap() {
+				src.Remove(k)
+			}
+		}
+		if s := dst.String(); s != tc.result {
+			t.Errorf("%s was modified by changing src", s)
+		}
+	}
+}
+
+func TestMergeConflicts(t *testing.T) {
+	dst := mustJSON(t, `{"a":1,"b":{"c":"x"},"d":[1]}`)
+	src := mustJSON(t, `{"a":"1","b":{"c":{"x":1}},"d":null}`)
+	_, err := Merge(dst, src, MergeOptions{ErrorOnConflict: true})
+	if err == nil {
+		t.Error("expected error")
+	}
+	if s := dst.String(); s != `{"a":1,"b":{"c":"x"},"d":[1]}` {
+		t.Error("dst was modified", s)
+	}
+	conflicts, err := Merge(dst, src, MergeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 2 || conflicts[0].Path != "/a" || conflicts[1].Path != "/b/c" ||
+		conflicts[0].String() != `/a: Number value 1 conflicts with Text value "1"` {
+		t.Error(conflicts)
+	}
+	if s := dst.String(); s != `{"a":"1","b":{"c":{"x":1}},"d":null}` {
+		t.Error(s)
+	}
+	if _, err := Merge(NewNode(1), src, MergeOptions{}); err == nil {
+		t.Error("merge into number should fail")
+	}
+	if _, err := Merge(dst, src, MergeOptions{Paths: map[string]MergeRule{"a": {}}}); err == nil {
+		t.Error("bad path should fail")
+	}
+}
+
+func TestMergeOverlappingPaths(t *testing.T) {
+	opts := MergeOptions{Paths: map[string]MergeRule{
+		"/a/*":   {Arrays: ArrayConcat},
+		"/a/b":   {Replace: true},
+		"/*/b":   {Arrays: ArrayUnion},
+		"/*/*":   {Arrays: ArrayReplace},
+		"/x/*/y": {Replace: true},
+	}}
+	for i := 0; i < 100; i++ {
+		dst := mustJSON(t, `{"a":{"b":[1,2],"c":[1,2]},"d":{"b":[1,2],"c":[1,2]}}`)
+		src := mustJSON(t, `{"a":{"b":[2,3],"c":[2,3]},"d":{"b":[2,3],"c":[2,3]}}`)
+		if _, err := Merge(dst, src, opts); err != nil {
+			t.Fatal(err)
+		}
+		if s := dst.String(); s != `{"a":{"b":[2,3],"c":[1,2,2,3]},"d":{"b":[1,2,3],"c":[2,3]}}` {
+			t.Fatal(i, s)
+		}
+	}
+}
@@ -0,0 +1,459 @@
+package jnode
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// maxMsgpackDepth limits the nesting of decoded MessagePack values.
+const maxMsgpackDepth = 1000
+
+// MarshalMsgpack encodes a Node as MessagePack.  Signed integers are
+// encoded with the int formats (or a fixint), unsigned integers with the
+// uint formats, float32 and float64 values as float 32 and float 64, and
+// Binary Nodes as bin, so FromMsgpack returns values of the same kind.
+// Objects are written in sorted key order.  An Object of the form
+// {"$ext": type, "data": Binary} is written as an ext value (see
+// MsgpackDecoder.)  A missing Node is encoded as nil.
+func (n *Node) MarshalMsgpack() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := NewMsgpackEncoder(buf).Encode(n); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// FromMsgpack creates a Node from a single MessagePack value.  Ext
+// values are decoded as described in MsgpackDecoder.
+func FromMsgpack(data []byte) (*Node, error) {
+	d := NewMsgpackDecoder(bytes.NewReader(data))
+	n, err := d.Decode()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return MissingNode, err
+	}
+	if d.More() {
+		return MissingNode, errors.New("msgpack: extra data after value")
+	}
+	return n, nil
+}
+
+// MsgpackEncoder writes MessagePack values to a stream.
+type MsgpackEncoder struct {
+	w   io.Writer
+	buf []byte
+}
+
+// NewMsgpackEncoder returns an encoder that writes to w.
+func NewMsgpackEncoder(w io.Writer) *MsgpackEncoder {
+	return &MsgpackEncoder{w: w}
+}
+
+// Encode writes the MessagePack encoding of n to the stream.
+func (e *MsgpackEncoder) Encode(n *Node) error {
+	e.buf = e.buf[:0]
+	var err error
+	if n != MissingNode {
+		err = e.encode(n.value)
+	} else {
+		e.buf = append(e.buf, 0xc0)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(e.buf)
+	return err
+}
+
+func (e *MsgpackEncoder) int(i int64) {
+	switch {
+	case i >= -32 && i <= math.MaxInt8:
+		e.buf = append(e.buf, byte(i))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		e.buf = append(e.buf, 0xd0, byte(i))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xd1), uint16(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xd2), uint32(i))
+	default:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, 0xd3), uint64(i))
+	}
+}
+
+func (e *MsgpackEncoder) uint(u uint64) {
+	switch {
+	case u <= math.MaxUint8:
+		e.buf = append(e.buf, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, 0xcd), uint16(u))
+	case u <= math.MaxUint32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xce), uint32(u))
+	default:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, 0xcf), u)
+	}
+}
+
+// head appends a length prefixed header using fix (if the length is
+// below fixMax) or the 8, 16 or 32 bit codes in codes.  A zero code
+// means that width isn't available.
+func (e *MsgpackEncoder) head(n int, fix byte, fixMax int, codes [3]byte) error {
+	switch {
+	case n < fixMax:
+		e.buf = append(e.buf, fix|byte(n))
+	case n <= math.MaxUint8 && codes[0] != 0:
+		e.buf = append(e.buf, codes[0], byte(n))
+	case n <= math.MaxUint16:
+		e.buf = binary.BigEndian.AppendUint16(append(e.buf, codes[1]), uint16(n))
+	case uint64(n) <= math.MaxUint32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, codes[2]), uint32(n))
+	default:
+		return fmt.Errorf("msgpack: length %d is too large", n)
+	}
+	return nil
+}
+
+func (e *MsgpackEncoder) str(s string) error {
+	if err := e.head(len(s), 0xa0, 32, [3]byte{0xd9, 0xda, 0xdb}); err != nil {
+		return err
+	}
+	e.buf = append(e.buf, s...)
+	return nil
+}
+
+func (e *MsgpackEncoder) ext(typ int8, data []byte) error {
+	fixed := map[int]byte{1: 0xd4, 2: 0xd5, 4: 0xd6, 8: 0xd7, 16: 0xd8}
+	if code, ok := fixed[len(data)]; ok {
+		e.buf = append(e.buf, code)
+	} else if err := e.head(len(data), 0, 0, [3]byte{0xc7, 0xc8, 0xc9}); err != nil {
+		return err
+	}
+	e.buf = append(append(e.buf, byte(typ)), data...)
+	return nil
+}
+
+func (e *MsgpackEncoder) encode(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		e.buf = append(e.buf, 0xc0)
+	case bool:
+		if v {
+			e.buf = append(e.buf, 0xc3)
+		} else {
+			e.buf = append(e.buf, 0xc2)
+		}
+	case string:
+		return e.str(v)
+	case []byte:
+		if err := e.head(len(v), 0, 0, [3]byte{0xc4, 0xc5, 0xc6}); err != nil {
+			return err
+		}
+		e.buf = append(e.buf, v...)
+	case int:
+		e.int(int64(v))
+	case int8:
+		e.int(int64(v))
+	case int16:
+		e.int(int64(v))
+	case int32:
+		e.int(int64(v))
+	case int64:
+		e.int(v)
+	case uint:
+		e.uint(uint64(v))
+	case uint8:
+		e.uint(uint64(v))
+	case uint16:
+		e.uint(uint64(v))
+	case uint32:
+		e.uint(uint64(v))
+	case uint64:
+		e.uint(v)
+	case float32:
+		e.buf = binary.BigEndian.AppendUint32(append(e.buf, 0xca), math.Float32bits(v))
+	case float64:
+		e.buf = binary.BigEndian.AppendUint64(append(e.buf, 0xcb), math.Float64bits(v))
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			e.int(i)
+		} else if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			e.uint(u)
+		} else if f, err := v.Float64(); err == nil {
+			return e.encode(f)
+		} else {
+			return fmt.Errorf("msgpack: invalid number %s", v)
+		}
+	case *[]interface{}:
+		if err := e.head(len(*v), 0x90, 16, [3]byte{0, 0xdc, 0xdd}); err != nil {
+			return err
+		}
+		for _, x := range *v {
+			if err := e.encode(x); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		if typ, data, ok := msgpackExt(v); ok {
+			return e.ext(typ, data)
+		}
+		if err := e.head(len(v), 0x80, 16, [3]byte{0, 0xde, 0xdf}); err != nil {
+			return err
+		}
+		for _, k := range sortedKeys(v) {
+			if err := e.str(k); err != nil {
+				return err
+			}
+			if err := e.encode(v[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: %T cannot be encoded", value)
+	}
+	return nil
+}
+
+// msgpackExt recognizes the Object form that ext values are decoded to.
+func msgpackExt(m map[string]interface{}) (int8, []byte, bool) {
+	if len(m) != 2 {
+		return 0, nil, false
+	}
+	data, ok := m["data"].([]byte)
+	if !ok {
+		return 0, nil, false
+	}
+	t := &Node{m["$ext"]}
+	if t.GetType() != Number {
+		return 0, nil, false
+	}
+	typ, err := As[int8](t)
+	return typ, data, err == nil
+}
+
+// MsgpackDecoder reads a stream of concatenated MessagePack values.
+//
+// Positive fixints and the int formats are decoded as int64, the uint
+// formats as uint64, float 32 as float32 and float 64 as float64, and
+// bin as Binary.  Map keys must be strings or integers (which are
+// converted to text.)
+//
+// Ext values are passed to Ext if it is set, which returns the Node to
+// use or an error (returning a nil Node is an error.)  Otherwise they
+// are decoded to an Object {"$ext": type, "data": Binary}, which
+// MarshalMsgpack writes back as the same ext value.
+type MsgpackDecoder struct {
+	Ext func(typ int8, data []byte) (*Node, error)
+	r   *bufio.Reader
+}
+
+// NewMsgpackDecoder returns a decoder that reads from r.  The decoder
+// buffers its input, so it may read beyond the values it returns.
+func NewMsgpackDecoder(r io.Reader) *MsgpackDecoder {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &MsgpackDecoder{r: br}
+}
+
+// Decode reads the next value from the stream.  At the end of the
+// stream it returns io.EOF, and if the stream ends in the middle of a
+// value it returns io.ErrUnexpectedEOF.
+func (d *MsgpackDecoder) Decode() (*Node, error) {
+	if _, err := d.r.Peek(1); err != nil {
+		return MissingNode, err
+	}
+	v, err := d.decode(0)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return MissingNode, err
+	}
+	return &Node{v}, nil
+}
+
+// More returns true if there is another value in the stream.
+func (d *MsgpackDecoder) More() bool {
+	_, err := d.r.Peek(1)
+	return err == nil
+}
+
+func (d *MsgpackDecoder) read(n int) ([]byte, error) {
+	if n <= 64 {
+		b := make([]byte, n)
+		_, err := io.ReadFull(d.r, b)
+		return b, err
+	}
+	// don't trust the length enough to allocate it all up front
+	buf := &bytes.Buffer{}
+	m, err := io.CopyN(buf, d.r, int64(n))
+	if err == io.EOF && m > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return buf.Bytes(), err
+}
+
+func (d *MsgpackDecoder) uint(size int) (uint64, error) {
+	b, err := d.read(size)
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, x := range b {
+		u = u<<8 | uint64(x)
+	}
+	return u, nil
+}
+
+func (d *MsgpackDecoder) decode(depth int) (interface{}, error) {
+	if depth > maxMsgpackDepth {
+		return nil, errors.New("msgpack: data is nested too deeply")
+	}
+	c, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c >= 0xa0 && c <= 0xbf:
+		return d.str(int(c & 0x1f))
+	case c >= 0x90 && c <= 0x9f:
+		return d.array(int(c&0x0f), depth)
+	case c >= 0x80 && c <= 0x8f:
+		return d.object(int(c&0x0f), depth)
+	}
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.uint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return d.read(int(n))
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.uint(1 << (c - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.ext(int(n))
+	case 0xca:
+		u, err := d.uint(4)
+		return math.Float32frombits(uint32(u)), err
+	case 0xcb:
+		u, err := d.uint(8)
+		return math.Float64frombits(u), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return d.uint(1 << (c - 0xcc))
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		u, err := d.uint(size)
+		// sign extend
+		shift := 64 - 8*size
+		return int64(u<<shift) >> shift, err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.ext(1 << (c - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(int(n))
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.array(int(n), depth)
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.object(int(n), depth)
+	default:
+		return nil, fmt.Errorf("msgpack: invalid code 0x%02x", c)
+	}
+}
+
+func (d *MsgpackDecoder) str(n int) (interface{}, error) {
+	b, err := d.read(n)
+	return string(b), err
+}
+
+func (d *MsgpackDecoder) ext(n int) (interface{}, error) {
+	t, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.read(n)
+	if err != nil {
+		return nil, err
+	}
+	if d.Ext != nil {
+		node, err := d.Ext(int8(t), data)
+		if err != nil {
+			return nil, err
+		}
+		if node == nil {
+			return nil, fmt.Errorf("msgpack: Ext returned no Node for ext type %d", int8(t))
+		}
+		return node.value, nil
+	}
+	return map[string]interface{}{"$ext": int64(int8(t)), "data": data}, nil
+}
+
+func (d *MsgpackDecoder) array(n int, depth int) (interface{}, error) {
+	a := make([]interface{}, 0, min(n, 1024))
+	for i := 0; i < n; i++ {
+		v, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+	}
+	return &a, nil
+}
+
+func (d *MsgpackDecoder) object(n int, depth int) (interface{}, error) {
+	m := make(map[string]interface{}, min(n, 1024))
+	for i := 0; i < n; i++ {
+		k, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		var key string
+		switch k := k.(type) {
+		case string:
+			key = k
+		case int64:
+			key = strconv.FormatInt(k, 10)
+		case uint64:
+			key = strconv.FormatUint(k, 10)
+		default:
+			return nil, fmt.Errorf("msgpack: unsupported map key type %T", k)
+		}
+		v, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+	return m, nil
+}
@@ -0,0 +1,144 @@
+package jnode
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	n := NewObjectNode().Put("i", -3).Put("big", int64(math.MinInt64)).Put("u", uint8(7)).
+		Put("umax", uint64(math.MaxUint64)).Put("f32", float32(1.25)).Put("f64", 2.5).
+		Put("b", []byte{9, 8}).Put("s", strings.Repeat("x", 300)).Put("n", nil).Put("t", true)
+	a := n.PutArray("a")
+	for i := 0; i < 20; i++ {
+		a.Append(i)
+	}
+	data, err := n.MarshalMsgpack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := FromMsgpack(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks := map[string]interface{}{
+		"i": int64(-3), "big": int64(math.MinInt64), "u": uint64(7),
+		"umax": uint64(math.MaxUint64), "f32": float32(1.25), "f64": 2.5,
+	}
+	for k, want := range checks {
+		if v := m.Path(k).Unwrap(); v != want {
+			t.Errorf("%s: %T %v", k, v, v)
+		}
+	}
+	if b, _ := m.Path("b").AsBinary(); m.Path("b").GetType() != Binary || !bytes.Equal(b, []byte{9, 8}) {
+		t.Error(m.Path("b"))
+	}
+	if m.Path("s").AsText() != strings.Repeat("x", 300) || !m.Path("n").IsNull() ||
+		!m.Path("t").AsBool() || m.Path("a").Size() != 20 || m.Path("a").Get(19).AsInt() != 19 {
+		t.Error(m)
+	}
+}
+
+func TestMsgpackEncoding(t *testing.T) {
+	tests := []struct {
+		n   *Node
+		hex string
+	}{
+		{NewNode(5), "05"},
+		{NewNode(-1), "ff"},
+		{NewNode(-33), "d0df"},
+		{NewNode(200), "d100c8"},
+		{NewNode(uint(5)), "cc05"},
+		{NewNode("a"), "a161"},
+		{NewNode([]byte{1}), "c40101"},
+		{NullNode, "c0"},
+		{MissingNode, "c0"},
+		{NewObjectNode().Put("b", 1).Put("a", false), "82a161c2a16201"},
+	}
+	for _, tc := range tests {
+		data, err := tc.n.MarshalMsgpack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := hex.EncodeToString(data); s != tc.hex {
+			t.Errorf("%s: %s", tc.n, s)
+		}
+	}
+}
+
+func TestMsgpackExt(t *testing.T) {
+	// a timestamp 32 ext and a 3 byte ext
+	data, _ := hex.DecodeString("92d6ff00000001c70305010203")
+	n, err := FromMsgpack(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := n.String(); s != `[{"$ext":-1,"data":"AAAAAQ=="},{"$ext":5,"data":"AQID"}]` {
+		t.Error(s)
+	}
+	again, err := n.MarshalMsgpack()
+	if err != nil || !bytes.Equal(again, data) {
+		t.Error(hex.EncodeToString(again), err)
+	}
+	d := NewMsgpackDecoder(bytes.NewReader(data))
+	d.Ext = func(typ int8, data []byte) (*Node, error) {
+		if typ == 5 {
+			return nil, errors.New("unsupported")
+		}
+		return NewNode(int(typ)), nil
+	}
+	if _, err := d.Decode(); err == nil || err.Error() != "unsupported" {
+		t.Error(err)
+	}
+	d = NewMsgpackDecoder(bytes.NewReader(data))
+	d.Ext = func(typ int8, data []byte) (*Node, error) {
+		return nil, nil
+	}
+	if _, err := d.Decode(); err == nil {
+		t.Error("nil Node from Ext should fail")
+	}
+}
+
+func TestMsgpackStream(t *testing.T) {
+	buf := &bytes.Buffer{}
+	e := NewMsgpackEncoder(buf)
+	for i := 0; i < 3; i++ {
+		if err := e.Encode(NewObjectNode().Put("i", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	d := NewMsgpackDecoder(buf)
+	for i := 0; d.More(); i++ {
+		n, err := d.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n.Path("i").AsInt() != i {
+			t.Error(i, n)
+		}
+	}
+	if _, err := d.Decode(); err != io.EOF {
+		t.Error(err)
+	}
+	d = NewMsgpackDecoder(bytes.NewReader([]byte{0x92, 0x01}))
+	if _, err := d.Decode(); err != io.ErrUnexpectedEOF {
+		t.Error(err)
+	}
+}
+
+func TestFromMsgpackErrors(t *testing.T) {
+	for _, in := range []string{"", "c1", "dbffffffff61", "0101", "81c0c0", "d9"} {
+		data, _ := hex.DecodeString(in)
+		if _, err := FromMsgpack(data); err == nil {
+			t.Errorf("%s: expected error", in)
+		}
+	}
+	if _, err := FromMsgpack(bytes.Repeat([]byte{0x91}, 2000)); err == nil {
+		t.Error("expected depth error")
+	}
+}
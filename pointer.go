@@ -0,0 +1,40 @@
+package jnode
+
+import (
+	"fmt"
+	"strings"
+)
+
+// pointerEscaper and pointerUnescaper handle the ~0 and ~1 escapes
+// of RFC 6901 JSON Pointers.
+var (
+	pointerEscaper   = strings.NewReplacer("~", "~0", "/", "~1")
+	pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+// parsePointer splits a JSON Pointer (e.g. "/a/b~1c/0") into its
+// unescaped reference tokens.  The empty pointer refers to the whole
+// document and has no tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = pointerUnescaper.Replace(t)
+	}
+	return tokens, nil
+}
+
+// formatPointer joins reference tokens into a JSON Pointer.
+func formatPointer(tokens []string) string {
+	var sb strings.Builder
+	for _, t := range tokens {
+		sb.WriteByte('/')
+		sb.WriteString(pointerEscaper.Replace(t))
+	}
+	return sb.String()
+}
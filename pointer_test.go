@@ -0,0 +1,30 @@
+package jnode
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPointer(t *testing.T) {
+	tests := []struct {
+		pointer string
+		tokens  []string
+	}{
+		{"", nil},
+		{"/", []string{""}},
+		{"/a/b~1c/~0d/0", []string{"a", "b/c", "~d", "0"}},
+		{"/~01", []string{"~1"}},
+	}
+	for _, tc := range tests {
+		tokens, err := parsePointer(tc.pointer)
+		if err != nil || !reflect.DeepEqual(tokens, tc.tokens) {
+			t.Error(tc.pointer, tokens, err)
+		}
+		if p := formatPointer(tokens); p != tc.pointer {
+			t.Error(p)
+		}
+	}
+	if _, err := parsePointer("a/b"); err == nil {
+		t.Error("pointer must start with /")
+	}
+}
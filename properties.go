@@ -0,0 +1,262 @@
+package jnode
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// PropertiesOptions control FromProperties.
+type PropertiesOptions struct {
+	// Nested splits keys on dots into nested Objects, so "a.b=1"
+	// becomes {"a":{"b":"1"}} rather than {"a.b":"1"}.
+	Nested bool
+}
+
+// FromProperties reads a Java .properties file into an Object Node
+// with Text values, following the rules of java.util.Properties: lines
+// starting with '#' or '!' are comments, the key ends at the first
+// unescaped '=', ':' or whitespace, a line ending with a backslash
+// continues on the next line, and the escapes \t, \n, \r, \f and
+// \uXXXX are recognized.  The data must be UTF-8 (or ISO 8859-1 that
+// only uses ASCII.)  When a key is repeated, the last value wins.
+func FromProperties(data []byte, opts PropertiesOptions) (*Node, error) {
+	m := make(map[string]interface{})
+	lines := splitLines(string(data))
+	for i := 0; i < len(lines); i++ {
+		number := i + 1
+		line := strings.TrimLeft(lines[i], " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		for continues(line) {
+			line = line[:len(line)-1]
+			if i+1 < len(lines) {
+				i++
+				line += strings.TrimLeft(lines[i], " \t\f")
+			}
+		}
+		key, value := splitProperty(line)
+		k, err := unescapeProperty(key)
+		if err == nil {
+			value, err = unescapeProperty(value)
+		}
+		if err != nil {
+			return MissingNode, fmt.Errorf("properties: line %d: %w", number, err)
+		}
+		keys := []string{k}
+		if opts.Nested {
+			keys = strings.Split(k, ".")
+		}
+		if err := putDotted(m, keys, value); err != nil {
+			return MissingNode, fmt.Errorf("properties: line %d: %w", number, err)
+		}
+	}
+	return &Node{m}, nil
+}
+
+// splitLines splits s into lines ending with \n, \r or \r\n.
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return strings.Split(s, "\n")
+}
+
+// continues returns true if line ends with an odd number of backslashes.
+func continues(line string) bool {
+	n := len(line) - len(strings.TrimRight(line, `\`))
+	return n%2 == 1
+}
+
+// splitProperty splits a logical line into its (still escaped) key
+// and value.
+func splitProperty(line string) (string, string) {
+	i := 0
+	for ; i < len(line); i++ {
+		if c := line[i]; c == '\\' {
+			i++
+		} else if strings.IndexByte("=: \t\f", c) >= 0 {
+			break
+		}
+	}
+	if i > len(line) {
+		i = len(line)
+	}
+	key := line[:i]
+	rest := strings.TrimLeft(line[i:], " \t\f")
+	if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+		rest = strings.TrimLeft(rest[1:], " \t\f")
+	}
+	return key, rest
+}
+
+func unescapeProperty(s string) (string, error) {
+	if strings.IndexByte(s, '\\') < 0 {
+		return s, nil
+	}
+	var sb strings.Builder
+	// high is a pending high surrogate, which is written as U+FFFD
+	// unless the next escape is a low surrogate.
+	var high rune
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if high != 0 && !strings.HasPrefix(s[i:], `\u`) {
+			sb.WriteRune(utf8.RuneError)
+			high = 0
+		}
+		if c != '\\' || i+1 == len(s) {
+			if c != '\\' {
+				sb.WriteByte(c)
+			}
+			continue
+		}
+		i++
+		switch c = s[i]; c {
+		case 't':
+			sb.WriteByte('\t')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", fmt.Errorf("malformed \\u escape")
+			}
+			u, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("malformed \\u%s escape", s[i+1:i+5])
+			}
+			i += 4
+			r := rune(u)
+			if high != 0 {
+				if r >= 0xdc00 && r <= 0xdfff {
+					sb.WriteRune(utf16.DecodeRune(high, r))
+					high = 0
+					continue
+				}
+				sb.WriteRune(utf8.RuneError)
+				high = 0
+			}
+			if r >= 0xd800 && r <= 0xdbff {
+				high = r
+				continue
+			}
+			sb.WriteRune(r)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	if high != 0 {
+		sb.WriteRune(utf8.RuneError)
+	}
+	return sb.String(), nil
+}
+
+// putDotted sets the value at keys within m, creating Objects as needed.
+// It is an error for a key to be both a value and an Object.
+func putDotted(m map[string]interface{}, keys []string, value interface{}) error {
+	for i, k := range keys[:len(keys)-1] {
+		switch sub := m[k].(type) {
+		case nil:
+			next := make(map[string]interface{})
+			m[k] = next
+			m = next
+		case map[string]interface{}:
+			m = sub
+		default:
+			return fmt.Errorf("%s is not an object", strings.Join(keys[:i+1], "."))
+		}
+	}
+	k := keys[len(keys)-1]
+	if _, ok := m[k].(map[string]interface{}); ok {
+		return fmt.Errorf("%s is an object", strings.Join(keys, "."))
+	}
+	m[k] = value
+	return nil
+}
+
+// ToProperties writes an Object Node as a Java .properties file, with
+// nested Objects flattened into dotted keys in sorted order.  Non-ASCII
+// characters are written as \uXXXX escapes.  Arrays and nulls can't be
+// represented, and empty Objects are omitted.  It is an error for two
+// values to have the same key, e.g. {"a.b": 1, "a": {"b": 2}}.
+func (n *Node) ToProperties() ([]byte, error) {
+	m, ok := n.value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("properties: not an object")
+	}
+	var sb strings.Builder
+	if err := writeProperties(&sb, "", m, make(map[string]bool)); err != nil {
+		return nil, err
+	}
+	return []byte(sb.String()), nil
+}
+
+// writeProperties writes the fields of m, recording their keys in written.
+func writeProperties(sb *strings.Builder, prefix string, m map[string]interface{}, written map[string]bool) error {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		key := prefix + k
+		switch v := m[k].(type) {
+		case map[string]interface{}:
+			if err := writeProperties(sb, key+".", v, written); err != nil {
+				return err
+			}
+		case *[]interface{}, nil:
+			return fmt.Errorf("properties: %s is %s, which can't be represented",
+				key, strings.ToLower((&Node{v}).GetType().String()))
+		default:
+			if written[key] {
+				return fmt.Errorf("properties: key %s is written more than once", key)
+			}
+			written[key] = true
+			writePropertyString(sb, key, true)
+			sb.WriteByte('=')
+			writePropertyString(sb, (&Node{v}).AsText(), false)
+			sb.WriteByte('\n')
+		}
+	}
+	return nil
+}
+
+func writePropertyString(sb *strings.Builder, s string, key bool) {
+	for i, r := range s {
+		switch r {
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '=', ':', '#', '!':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case ' ':
+			if key || i == 0 {
+				sb.WriteByte('\\')
+			}
+			sb.WriteByte(' ')
+		default:
+			if r < 0x20 || r > 0x7e {
+				for _, u := range utf16.Encode([]rune{r}) {
+					fmt.Fprintf(sb, `\u%04X`, u)
+				}
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+}
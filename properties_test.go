@@ -0,0 +1,106 @@
+package jnode
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFromProperties(t *testing.T) {
+	in := "# comment\n" +
+		"! also a comment \\\n" +
+		"db.host = localhost\n" +
+		"db.port:5432\n" +
+		"db.url    jdbc:postgresql://x/y?a=b\n" +
+		"  fruits  apple, banana, \\\n" +
+		"          pear\n" +
+		"key\\ with\\ spaces = \\ leading space\n" +
+		"tab=a\\tb\\nc\n" +
+		"unicode=caf\\u00e9 \\uD83D\\uDE00\n" +
+		"empty\n" +
+		"dup=1\r\n" +
+		"dup=2\r\n"
+	n, err := FromProperties([]byte(in), PropertiesOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"db.host":"localhost","db.port":"5432","db.url":"jdbc:postgresql://x/y?a=b","dup":"2","empty":"",` +
+		`"fruits":"apple, banana, pear","key with spaces":" leading space","tab":"a\tb\nc","unicode":"café 😀"}`
+	if s := n.String(); s != want {
+		t.Error(s)
+	}
+	n, err = FromProperties([]byte(in), PropertiesOptions{Nested: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := n.Path("db").String(); s != `{"host":"localhost","port":"5432","url":"jdbc:postgresql://x/y?a=b"}` {
+		t.Error(s)
+	}
+}
+
+func TestFromPropertiesErrors(t *testing.T) {
+	tests := map[string]string{
+		"a=\\u12":           "line 1: malformed",
+		"a=1\nb=\\uzzzz":    "line 2: malformed",
+		"a=1\na.b=2":        "line 2: a is not an object",
+		"a.b=1\na=2":        "line 2: a is an object",
+		"x=1\n\na.b=1\na=2": "line 4",
+	}
+	for in, want := range tests {
+		_, err := FromProperties([]byte(in), PropertiesOptions{Nested: true})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: %v", in, err)
+		}
+	}
+}
+
+func TestToProperties(t *testing.T) {
+	n, _ := FromJSON([]byte(`{"db":{"host":"localhost","port":5432,"opts":{"ssl":true}},` +
+		`"a key":" x=y:z #!\\","café":"😀\n","f":1.5}`))
+	b, err := n.ToProperties()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "a\\ key=\\ x\\=y\\:z \\#\\!\\\\\n" +
+		"caf\\u00E9=\\uD83D\\uDE00\\n\n" +
+		"db.host=localhost\n" +
+		"db.opts.ssl=true\n" +
+		"db.port=5432\n" +
+		"f=1.5\n"
+	if string(b) != want {
+		t.Error(string(b))
+	}
+	back, err := FromProperties(b, PropertiesOptions{Nested: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := back.String(); s != `{"a key":" x=y:z #!\\","café":"😀\n","db":{"host":"localhost","opts":{"ssl":"true"},"port":"5432"},"f":"1.5"}` {
+		t.Error(s)
+	}
+	for _, in := range []string{`[]`, `{"a":null}`, `{"a":{"b":[1]}}`, `{"a.b":"1","a":{"b":"2"}}`,
+		`{"a":{"b.c":1},"a.b":{"c":2}}`} {
+		n, _ := FromJSON([]byte(in))
+		if _, err := n.ToProperties(); err == nil {
+			t.Error(in)
+		}
+	}
+}
+
+func TestPropertiesSurrogates(t *testing.T) {
+	tests := map[string]string{
+		"\\uD83D\\uDE00":        "\U0001F600",
+		"\\uD800x\\u0041":       "\uFFFDxA",
+		"\\uD800\\uD83D\\uDE00": "\uFFFD\U0001F600",
+		"\\uDE00a":              "\uFFFDa",
+		"a\\uD800":              "a\uFFFD",
+		"\\uD800\\nA":           "\uFFFD\nA",
+		"\\uD800\\uD800\\uDC00": "\uFFFD\U00010000",
+	}
+	for in, want := range tests {
+		n, err := FromProperties([]byte("k="+in), PropertiesOptions{})
+		if err != nil {
+			t.Errorf("%s: %v", in, err)
+		} else if s := n.Path("k").AsText(); s != want {
+			t.Errorf("%s: %q", in, s)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package jnode
+
+import (
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// RefLoader loads the documents that JSON References point to.  The
+// uri is the reference resolved against the URI of the referring
+// document, without the fragment.
+type RefLoader interface {
+	Load(uri string) (*Node, error)
+}
+
+// MapLoader is a RefLoader that holds documents in memory,
+// keyed by URI.
+type MapLoader map[string]*Node
+
+// Load returns the document for uri.
+func (m MapLoader) Load(uri string) (*Node, error) {
+	if n, ok := m[uri]; ok {
+		return n, nil
+	}
+	return nil, fmt.Errorf("document %s not found", uri)
+}
+
+type fsLoader struct {
+	fsys fs.FS
+}
+
+// FSLoader returns a RefLoader that reads JSON documents from a
+// file system.  URIs are treated as slash separated paths relative
+// to the root of fsys.
+func FSLoader(fsys fs.FS) RefLoader {
+	return &fsLoader{fsys}
+}
+
+func (l *fsLoader) Load(uri string) (*Node, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "" && u.Scheme != "file" {
+		return nil, fmt.Errorf("cannot load %s from a file system", uri)
+	}
+	name := strings.TrimPrefix(path.Clean(u.Path), "/")
+	data, err := fs.ReadFile(l.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return FromJSON(data)
+}
+
+// RefResolver resolves JSON References, i.e. objects of the form
+// {"$ref": "uri#/json/pointer"}, both within a document and across
+// documents loaded with a RefLoader.  A reference is resolved against
+// the URI of the document that contains it, so "common.json#/defs/x" in
+// "schemas/main.json" refers to "schemas/common.json".  Fragments must
+// be JSON Pointers (or empty.)  Any other fields of a reference object
+// are ignored.  Documents are loaded once and cached.
+type RefResolver struct {
+	loader RefLoader
+	docs   map[string]*Node
+}
+
+// NewRefResolver creates a RefResolver that loads documents
+// with loader, which may be nil if only references within the
+// document are used.
+func NewRefResolver(loader RefLoader) *RefResolver {
+	return &RefResolver{loader: loader, docs: make(map[string]*Node)}
+}
+
+// Inline returns a copy of doc with every reference replaced by
+// (a copy of) its target, recursively.  uri is the URI of doc, which
+// may be "".  Returns an error if a reference can't be resolved or
+// references are circular.
+func (r *RefResolver) Inline(doc *Node, uri string) (*Node, error) {
+	r.docs[docURI(uri)] = doc
+	v, err := r.inline(doc, docURI(uri), nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Node{v}, nil
+}
+
+func (r *RefResolver) inline(n *Node, uri string, stack []string) (interface{}, error) {
+	if ref, ok := refOf(n); ok {
+		target, targetURI, err := r.Resolve(uri, ref)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range stack {
+			if s == targetURI {
+				return nil, fmt.Errorf("circular $ref %s in %s", ref, uri)
+			}
+		}
+		return r.inline(target, docURI(targetURI), append(stack, targetURI))
+	}
+	switch n.GetType() {
+	case Object:
+		m := make(map[string]interface{}, n.Size())
+		for k, e := range n.Entries() {
+			v, err := r.inline(e, uri, stack)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = v
+		}
+		return m, nil
+	case Array:
+		a := make([]interface{}, n.Size())
+		for i, e := range n.Elements() {
+			v, err := r.inline(e, uri, stack)
+			if err != nil {
+				return nil, err
+			}
+			a[i] = v
+		}
+		return &a, nil
+	default:
+		return n.value, nil
+	}
+}
+
+// Resolve resolves a single reference made from the document at
+// base, and returns the target and its absolute URI (including
+// the fragment.)  The target itself may be a reference.
+func (r *RefResolver) Resolve(base, ref string) (*Node, string, error) {
+	b, err := url.Parse(base)
+	if err != nil {
+		return nil, "", err
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid $ref %q: %w", ref, err)
+	}
+	u = b.ResolveReference(u)
+	fragment := u.Fragment
+	u.Fragment = ""
+	uri := u.String()
+	doc, ok := r.docs[uri]
+	if !ok {
+		if r.loader == nil {
+			return nil, "", fmt.Errorf("cannot load %s for $ref %q", uri, ref)
+		}
+		if doc, err = r.loader.Load(uri); err != nil {
+			return nil, "", fmt.Errorf("cannot load %s for $ref %q: %w", uri, ref, err)
+		}
+		r.docs[uri] = doc
+	}
+	tokens, err := parsePointer(fragment)
+	if err != nil {
+		return nil, "", fmt.Errorf("unsupported fragment in $ref %q", ref)
+	}
+	target := doc
+	for _, t := range tokens {
+		if target.IsArray() {
+			i, err := strconv.Atoi(t)
+			if err != nil {
+				target = MissingNode
+				break
+			}
+			target = target.Get(i)
+		} else {
+			target = target.Path(t)
+		}
+	}
+	if target.IsMissing() {
+		return nil, "", fmt.Errorf("$ref %q not found", ref)
+	}
+	if fragment != "" {
+		uri += "#" + fragment
+	}
+	return target, uri, nil
+}
+
+// View returns a lazily resolving view of doc, which follows
+// references as it is navigated.
+func (r *RefResolver) View(doc *Node, uri string) *RefView {
+	r.docs[docURI(uri)] = doc
+	return r.view(doc, docURI(uri))
+}
+
+func (r *RefResolver) view(n *Node, uri string) *RefView {
+	seen := map[string]bool{}
+	for {
+		ref, ok := refOf(n)
+		if !ok {
+			return &RefView{r: r, node: n, uri: uri}
+		}
+		target, targetURI, err := r.Resolve(uri, ref)
+		if err == nil && seen[targetURI] {
+			err = fmt.Errorf("circular $ref %s in %s", ref, uri)
+		}
+		if err != nil {
+			return &RefView{r: r, node: MissingNode, uri: uri, err: err}
+		}
+		seen[targetURI] = true
+		n, uri = target, docURI(targetURI)
+	}
+}
+
+// RefView is a view of a document in which references are resolved
+// as it is navigated.  Unlike Inline, a RefView can be used with
+// recursive (circular) references.
+type RefView struct {
+	r    *RefResolver
+	node *Node
+	uri  string
+	err  error
+}
+
+// Node returns the (resolved) Node of the view.  Fields and
+// elements of the Node may still be references.
+func (v *RefView) Node() *Node {
+	return v.node
+}
+
+// URI returns the URI of the document that contains the Node.
+func (v *RefView) URI() string {
+	return v.uri
+}
+
+// Err returns the error, if any, from resolving the reference
+// that led to this view.  The Node of the view is MissingNode if
+// there was an error.
+func (v *RefView) Err() error {
+	return v.err
+}
+
+// Path returns a view of a field of an object, following a
+// reference if the field is one.
+func (v *RefView) Path(name string) *RefView {
+	if v.err != nil {
+		return v
+	}
+	return v.r.view(v.node.Path(name), v.uri)
+}
+
+// Get returns a view of an element of an array, following a
+// reference if the element is one.
+func (v *RefView) Get(i int) *RefView {
+	if v.err != nil {
+		return v
+	}
+	return v.r.view(v.node.Get(i), v.uri)
+}
+
+// Entries returns views of the fields of an object.
+func (v *RefView) Entries() map[string]*RefView {
+	e := make(map[string]*RefView)
+	for k := range v.node.Entries() {
+		e[k] = v.Path(k)
+	}
+	return e
+}
+
+// Elements returns views of the elements of an array.
+func (v *RefView) Elements() []*RefView {
+	var e []*RefView
+	for i := range v.node.Elements() {
+		e = append(e, v.Get(i))
+	}
+	return e
+}
+
+// refOf returns the value of $ref if n is a reference object.
+func refOf(n *Node) (string, bool) {
+	if !n.IsObject() {
+		return "", false
+	}
+	ref := n.Path("$ref")
+	if ref.GetType() != Text {
+		return "", false
+	}
+	return ref.AsText(), true
+}
+
+func docURI(uri string) string {
+	if i := strings.IndexByte(uri, '#'); i >= 0 {
+		return uri[:i]
+	}
+	return uri
+}
@@ -0,0 +1,104 @@
+package jnode
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func testRefFS() fstest.MapFS {
+	return fstest.MapFS{
+		"schemas/main.json": {Data: []byte(`{
+			"person": {"$ref": "common.json#/definitions/person"},
+			"local": {"$ref": "#/definitions/id"},
+			"list": [{"$ref": "#/definitions/id"}, {"$ref": "/top.json"}],
+			"definitions": {"id": {"type": "string"}}
+		}`)},
+		"schemas/common.json": {Data: []byte(`{
+			"definitions": {
+				"person": {"properties": {"name": {"$ref": "#/definitions/name"}, "id": {"$ref": "main.json#/definitions/id"}}},
+				"name": {"type": "string", "minLength": 1},
+				"tree": {"properties": {"children": {"items": {"$ref": "#/definitions/tree"}}}},
+				"a": {"$ref": "#/definitions/b"},
+				"b": {"$ref": "#/definitions/a"}
+			}
+		}`)},
+		"top.json": {Data: []byte(`{"type":"integer"}`)},
+	}
+}
+
+func TestRefInline(t *testing.T) {
+	r := NewRefResolver(FSLoader(testRefFS()))
+	main, err := FSLoader(testRefFS()).Load("schemas/main.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := r.Inline(main, "schemas/main.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"definitions":{"id":{"type":"string"}},"list":[{"type":"string"},{"type":"integer"}],` +
+		`"local":{"type":"string"},` +
+		`"person":{"properties":{"id":{"type":"string"},"name":{"minLength":1,"type":"string"}}}}`
+	if s := n.String(); s != expected {
+		t.Error(s)
+	}
+	if main.Path("local").Path("$ref").IsMissing() {
+		t.Error("document was modified")
+	}
+}
+
+func TestRefCycles(t *testing.T) {
+	r := NewRefResolver(FSLoader(testRefFS()))
+	tree, _, err := r.Resolve("schemas/common.json", "#/definitions/tree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Inline(tree, "schemas/common.json"); err == nil {
+		t.Error("recursive inline should fail")
+	}
+	v := r.View(tree, "schemas/common.json")
+	items := v.Path("properties").Path("children").Path("items")
+	if items.Err() != nil || items.Path("properties").Path("children").Node().IsMissing() {
+		t.Error(items.Err(), items.Node())
+	}
+	a := r.View(mustJSON(t, `{"$ref":"schemas/common.json#/definitions/a"}`), "")
+	if a.Err() == nil || !a.Node().IsMissing() || !a.Path("x").Node().IsMissing() {
+		t.Error(a.Node())
+	}
+}
+
+func TestRefView(t *testing.T) {
+	loader := MapLoader{
+		"http://example.com/a.json": mustJSON(t, `{"x":{"$ref":"b.json#/y"},"list":[1,{"$ref":"#/z"}],"z":3}`),
+		"http://example.com/b.json": mustJSON(t, `{"y":{"value":{"$ref":"#/w"}},"w":"hello"}`),
+	}
+	r := NewRefResolver(loader)
+	v := r.View(loader["http://example.com/a.json"], "http://example.com/a.json")
+	x := v.Path("x")
+	if x.URI() != "http://example.com/b.json" || x.Path("value").Node().AsText() != "hello" {
+		t.Error(x.URI(), x.Node())
+	}
+	e := v.Path("list").Elements()
+	if len(e) != 2 || e[1].Node().AsInt() != 3 {
+		t.Error(e)
+	}
+	if len(v.Entries()) != 3 {
+		t.Error(v.Entries())
+	}
+	bad := v.Path("list").Get(5)
+	if bad.Err() != nil || !bad.Node().IsMissing() {
+		t.Error(bad)
+	}
+	if _, _, err := r.Resolve("http://example.com/a.json", "c.json"); err == nil {
+		t.Error("missing document should fail")
+	}
+	if _, _, err := r.Resolve("http://example.com/a.json", "#/nothing"); err == nil {
+		t.Error("missing pointer should fail")
+	}
+	if _, _, err := r.Resolve("http://example.com/a.json", "#anchor"); err == nil {
+		t.Error("anchor should fail")
+	}
+	if _, _, err := NewRefResolver(nil).Resolve("", "x.json"); err == nil {
+		t.Error("no loader should fail")
+	}
+}
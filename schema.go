@@ -0,0 +1,583 @@
+package jnode
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is a compiled JSON Schema.  The core validation keywords of
+// draft 2020-12 are supported: type, enum, const, properties,
+// patternProperties, additionalProperties, required, minProperties,
+// maxProperties, prefixItems, items, minItems, maxItems, uniqueItems,
+// minLength, maxLength, pattern, minimum, maximum, exclusiveMinimum,
+// exclusiveMaximum, multipleOf, allOf, anyOf, oneOf, not, and $ref to
+// locations within the same document (e.g. "#/$defs/address".)  Other
+// keywords (including format) are ignored.  Patterns are Go regular
+// expressions, which differ slightly from the ECMA 262 regular
+// expressions required by the specification.
+type Schema struct {
+	root *schemaNode
+}
+
+// ValidationError describes a single way that a Node fails to
+// match a Schema.
+type ValidationError struct {
+	// InstancePath is a JSON Pointer to the invalid value.
+	InstancePath string
+	// KeywordPath is a JSON Pointer to the schema keyword that
+	// failed.
+	KeywordPath string
+	Message     string
+}
+
+func (e *ValidationError) Error() string {
+	path := e.InstancePath
+	if path == "" {
+		path = "(root)"
+	}
+	return fmt.Sprintf("%s: %s", path, e.Message)
+}
+
+// ValidationErrors is the error returned by Schema.Validate,
+// and contains all the ways a Node fails to match a Schema.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, ve := range e {
+		msgs[i] = ve.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+type schemaNode struct {
+	path    string
+	always  *bool
+	types   []string
+	enum    []*Node
+	constV  *Node
+	ref     *schemaNode
+	refPath string
+
+	properties        map[string]*schemaNode
+	patternProperties []*patternSchema
+	additional        *schemaNode
+	required          []string
+	minProperties     *float64
+	maxProperties     *float64
+
+	prefixItems []*schemaNode
+	items       *schemaNode
+	minItems    *float64
+	maxItems    *float64
+	uniqueItems bool
+
+	minLength *float64
+	maxLength *float64
+	pattern   *regexp.Regexp
+
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum *float64
+	exclusiveMaximum *float64
+	multipleOf       *float64
+
+	allOf []*schemaNode
+	anyOf []*schemaNode
+	oneOf []*schemaNode
+	not   *schemaNode
+}
+
+type patternSchema struct {
+	re     *regexp.Regexp
+	schema *schemaNode
+}
+
+type schemaCompiler struct {
+	doc   *Node
+	nodes map[string]*schemaNode
+}
+
+// CompileSchema compiles a JSON Schema document.  It returns an
+// error if the schema is invalid or contains a $ref that can't be
+// resolved.
+func CompileSchema(schema *Node) (*Schema, error) {
+	c := &schemaCompiler{doc: schema, nodes: make(map[string]*schemaNode)}
+	root, err := c.compile(schema, "")
+	if err != nil {
+		return nil, err
+	}
+	return &Schema{root}, nil
+}
+
+func (c *schemaCompiler) errorf(path, format string, args ...interface{}) error {
+	if path == "" {
+		path = "(root)"
+	}
+	return fmt.Errorf("invalid schema at %s: %s", path, fmt.Sprintf(format, args...))
+}
+
+func (c *schemaCompiler) compile(n *Node, path string) (*schemaNode, error) {
+	if s, ok := c.nodes[path]; ok {
+		return s, nil
+	}
+	s := &schemaNode{path: path}
+	c.nodes[path] = s
+	switch n.GetType() {
+	case Bool:
+		b := n.AsBool()
+		s.always = &b
+		return s, nil
+	case Object:
+	default:
+		return nil, c.errorf(path, "schema must be an object or a boolean")
+	}
+	entries := n.Entries()
+	for _, k := range sortedKeys(n.ToMap()) {
+		if err := c.compileKeyword(s, k, entries[k], path+"/"+pointerEscaper.Replace(k)); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (c *schemaCompiler) compileKeyword(s *schemaNode, k string, v *Node, path string) error {
+	var err error
+	switch k {
+	case "type":
+		err = c.compileType(s, v, path)
+	case "enum":
+		if !v.IsArray() {
+			return c.errorf(path, "enum must be an array")
+		}
+		s.enum = v.Elements()
+	case "const":
+		s.constV = v
+	case "$ref":
+		err = c.compileRef(s, v, path)
+	case "properties":
+		s.properties, err = c.compileMap(v, path)
+	case "patternProperties":
+		var m map[string]*schemaNode
+		if m, err = c.compileMap(v, path); err == nil {
+			for _, p := range sortedKeys(v.ToMap()) {
+				var re *regexp.Regexp
+				if re, err = regexp.Compile(p); err != nil {
+					return c.errorf(path, "invalid pattern %q: %v", p, err)
+				}
+				s.patternProperties = append(s.patternProperties, &patternSchema{re, m[p]})
+			}
+		}
+	case "additionalProperties":
+		s.additional, err = c.compile(v, path)
+	case "required":
+		if !v.IsArray() {
+			return c.errorf(path, "required must be an array of strings")
+		}
+		for _, e := range v.Elements() {
+			if e.GetType() != Text {
+				return c.errorf(path, "required must be an array of strings")
+			}
+			s.required = append(s.required, e.AsText())
+		}
+	case "prefixItems":
+		s.prefixItems, err = c.compileList(v, path)
+	case "items":
+		s.items, err = c.compile(v, path)
+	case "uniqueItems":
+		if v.GetType() != Bool {
+			return c.errorf(path, "uniqueItems must be a boolean")
+		}
+		s.uniqueItems = v.AsBool()
+	case "pattern":
+		if v.GetType() != Text {
+			return c.errorf(path, "pattern must be a string")
+		}
+		if s.pattern, err = regexp.Compile(v.AsText()); err != nil {
+			return c.errorf(path, "invalid pattern: %v", err)
+		}
+	case "minProperties", "maxProperties", "minItems", "maxItems", "minLength", "maxLength",
+		"minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "multipleOf":
+		if !v.IsNumber() {
+			return c.errorf(path, "%s must be a number", k)
+		}
+		f := v.AsFloat()
+		if k == "multipleOf" && !(f > 0) {
+			return c.errorf(path, "multipleOf must be greater than 0")
+		}
+		*s.numberKeyword(k) = &f
+	case "allOf":
+		s.allOf, err = c.compileList(v, path)
+	case "anyOf":
+		s.anyOf, err = c.compileList(v, path)
+	case "oneOf":
+		s.oneOf, err = c.compileList(v, path)
+	case "not":
+		s.not, err = c.compile(v, path)
+	case "$defs", "definitions":
+		_, err = c.compileMap(v, path)
+	}
+	return err
+}
+
+func (s *schemaNode) numberKeyword(k string) **float64 {
+	switch k {
+	case "minProperties":
+		return &s.minProperties
+	case "maxProperties":
+		return &s.maxProperties
+	case "minItems":
+		return &s.minItems
+	case "maxItems":
+		return &s.maxItems
+	case "minLength":
+		return &s.minLength
+	case "maxLength":
+		return &s.maxLength
+	case "minimum":
+		return &s.minimum
+	case "maximum":
+		return &s.maximum
+	case "exclusiveMinimum":
+		return &s.exclusiveMinimum
+	case "exclusiveMaximum":
+		return &s.exclusiveMaximum
+	default:
+		return &s.multipleOf
+	}
+}
+
+var schemaTypes = map[string]bool{
+	"null": true, "boolean": true, "object": true, "array": true,
+	"number": true, "integer": true, "string": true,
+}
+
+func (c *schemaCompiler) compileType(s *schemaNode, v *Node, path string) error {
+	types := []*Node{v}
+	if v.IsArray() {
+		types = v.Elements()
+	}
+	for _, t := range types {
+		if t.GetType() != Text || !schemaTypes[t.AsText()] {
+			return c.errorf(path, "invalid type %s", t)
+		}
+		s.types = append(s.types, t.AsText())
+	}
+	return nil
+}
+
+func (c *schemaCompiler) compileRef(s *schemaNode, v *Node, path string) error {
+	ref := v.AsText()
+	if v.GetType() != Text || !strings.HasPrefix(ref, "#") {
+		return c.errorf(path, "unsupported $ref %s", v)
+	}
+	pointer, err := url.PathUnescape(ref[1:])
+	if err != nil {
+		return c.errorf(path, "invalid $ref %q", ref)
+	}
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return c.errorf(path, "unsupported $ref %q", ref)
+	}
+	target := c.doc
+	for _, t := range tokens {
+		if target.IsArray() {
+			i, err := strconv.Atoi(t)
+			if err != nil {
+				return c.errorf(path, "$ref %q not found", ref)
+			}
+			target = target.Get(i)
+		} else {
+			target = target.Path(t)
+		}
+	}
+	if target.IsMissing() {
+		return c.errorf(path, "$ref %q not found", ref)
+	}
+	s.refPath = ref
+	s.ref, err = c.compile(target, formatPointer(tokens))
+	return err
+}
+
+func (c *schemaCompiler) compileMap(v *Node, path string) (map[string]*schemaNode, error) {
+	if !v.IsObject() {
+		return nil, c.errorf(path, "must be an object")
+	}
+	m := make(map[string]*schemaNode)
+	entries := v.Entries()
+	for _, k := range sortedKeys(v.ToMap()) {
+		s, err := c.compile(entries[k], path+"/"+pointerEscaper.Replace(k))
+		if err != nil {
+			return nil, err
+		}
+		m[k] = s
+	}
+	return m, nil
+}
+
+func (c *schemaCompiler) compileList(v *Node, path string) ([]*schemaNode, error) {
+	if !v.IsArray() || v.Size() == 0 {
+		return nil, c.errorf(path, "must be a non-empty array")
+	}
+	var list []*schemaNode
+	for i, e := range v.Elements() {
+		s, err := c.compile(e, fmt.Sprintf("%s/%d", path, i))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+// Validate validates a Node against the Schema.  If the Node is
+// not valid the error is a ValidationErrors listing all of the
+// problems found.
+func (s *Schema) Validate(n *Node) error {
+	if errs := s.root.validate(n, nil, 0); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// maxSchemaDepth guards against $ref cycles that don't consume
+// any of the instance, e.g. {"$ref": "#"}.
+const maxSchemaDepth = 1000
+
+func (s *schemaNode) validate(n *Node, path []string, depth int) ValidationErrors {
+	var errs ValidationErrors
+	fail := func(keyword, format string, args ...interface{}) {
+		errs = append(errs, &ValidationError{
+			InstancePath: formatPointer(path),
+			KeywordPath:  s.path + "/" + keyword,
+			Message:      fmt.Sprintf(format, args...),
+		})
+	}
+	if depth > maxSchemaDepth {
+		fail("$ref", "schema recursion is too deep")
+		return errs
+	}
+	if s.always != nil {
+		if !*s.always {
+			errs = append(errs, &ValidationError{
+				InstancePath: formatPointer(path),
+				KeywordPath:  s.path,
+				Message:      "no value is allowed",
+			})
+		}
+		return errs
+	}
+	if s.ref != nil {
+		errs = append(errs, s.ref.validate(n, path, depth+1)...)
+	}
+	if len(s.types) > 0 && !s.matchesType(n) {
+		fail("type", "expected %s, got %s", strings.Join(s.types, " or "), schemaTypeOf(n))
+	}
+	if s.enum != nil && indexOfValue(s.enum, n) < 0 {
+		fail("enum", "value %s is not one of %s", n, nodesString(s.enum))
+	}
+	if s.constV != nil && s.constV.String() != n.String() {
+		fail("const", "value %s is not %s", n, s.constV)
+	}
+	switch n.GetType() {
+	case Object:
+		s.validateObject(n, path, depth, &errs, fail)
+	case Array:
+		s.validateArray(n, path, depth, &errs, fail)
+	case Text:
+		str := n.AsText()
+		l := float64(utf8.RuneCountInString(str))
+		if s.minLength != nil && l < *s.minLength {
+			fail("minLength", "length %v is less than %v", l, *s.minLength)
+		}
+		if s.maxLength != nil && l > *s.maxLength {
+			fail("maxLength", "length %v is greater than %v", l, *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(str) {
+			fail("pattern", "%q does not match pattern %q", str, s.pattern)
+		}
+	case Number:
+		s.validateNumber(n.AsFloat(), fail)
+	}
+	for _, sub := range s.allOf {
+		errs = append(errs, sub.validate(n, path, depth+1)...)
+	}
+	if s.anyOf != nil {
+		matched := false
+		for _, sub := range s.anyOf {
+			if len(sub.validate(n, path, depth+1)) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("anyOf", "value does not match any of the schemas")
+		}
+	}
+	if s.oneOf != nil {
+		var matches []string
+		for i, sub := range s.oneOf {
+			if len(sub.validate(n, path, depth+1)) == 0 {
+				matches = append(matches, strconv.Itoa(i))
+			}
+		}
+		switch len(matches) {
+		case 0:
+			fail("oneOf", "value does not match any of the schemas")
+		case 1:
+		default:
+			fail("oneOf", "value matches more than one schema (%s)", strings.Join(matches, ", "))
+		}
+	}
+	if s.not != nil && len(s.not.validate(n, path, depth+1)) == 0 {
+		fail("not", "value must not match the schema")
+	}
+	return errs
+}
+
+func (s *schemaNode) validateObject(n *Node, path []string, depth int,
+	errs *ValidationErrors, fail func(string, string, ...interface{})) {
+	m := n.ToMap()
+	size := float64(len(m))
+	if s.minProperties != nil && size < *s.minProperties {
+		fail("minProperties", "object has %v properties, fewer than %v", size, *s.minProperties)
+	}
+	if s.maxProperties != nil && size > *s.maxProperties {
+		fail("maxProperties", "object has %v properties, more than %v", size, *s.maxProperties)
+	}
+	for _, r := range s.required {
+		if _, ok := m[r]; !ok {
+			fail("required", "missing required property %q", r)
+		}
+	}
+	for _, k := range sortedKeys(m) {
+		v := &Node{m[k]}
+		p := appendPath(path, k)
+		matched := false
+		if ps, ok := s.properties[k]; ok {
+			matched = true
+			*errs = append(*errs, ps.validate(v, p, depth+1)...)
+		}
+		for _, pp := range s.patternProperties {
+			if pp.re.MatchString(k) {
+				matched = true
+				*errs = append(*errs, pp.schema.validate(v, p, depth+1)...)
+			}
+		}
+		if !matched && s.additional != nil {
+			if s.additional.always != nil && !*s.additional.always {
+				fail("additionalProperties", "property %q is not allowed", k)
+			} else {
+				*errs = append(*errs, s.additional.validate(v, p, depth+1)...)
+			}
+		}
+	}
+}
+
+func (s *schemaNode) validateArray(n *Node, path []string, depth int,
+	errs *ValidationErrors, fail func(string, string, ...interface{})) {
+	elements := n.Elements()
+	size := float64(len(elements))
+	if s.minItems != nil && size < *s.minItems {
+		fail("minItems", "array has %v items, fewer than %v", size, *s.minItems)
+	}
+	if s.maxItems != nil && size > *s.maxItems {
+		fail("maxItems", "array has %v items, more than %v", size, *s.maxItems)
+	}
+	for i, e := range elements {
+		p := appendPath(path, strconv.Itoa(i))
+		if i < len(s.prefixItems) {
+			*errs = append(*errs, s.prefixItems[i].validate(e, p, depth+1)...)
+		} else if s.items != nil {
+			*errs = append(*errs, s.items.validate(e, p, depth+1)...)
+		}
+	}
+	if s.uniqueItems {
+		for i := 1; i < len(elements); i++ {
+			if j := indexOfValue(elements[:i], elements[i]); j >= 0 {
+				fail("uniqueItems", "items %d and %d are equal", j, i)
+			}
+		}
+	}
+}
+
+func (s *schemaNode) validateNumber(f float64, fail func(string, string, ...interface{})) {
+	if s.minimum != nil && f < *s.minimum {
+		fail("minimum", "%v is less than %v", f, *s.minimum)
+	}
+	if s.maximum != nil && f > *s.maximum {
+		fail("maximum", "%v is greater than %v", f, *s.maximum)
+	}
+	if s.exclusiveMinimum != nil && f <= *s.exclusiveMinimum {
+		fail("exclusiveMinimum", "%v is not greater than %v", f, *s.exclusiveMinimum)
+	}
+	if s.exclusiveMaximum != nil && f >= *s.exclusiveMaximum {
+		fail("exclusiveMaximum", "%v is not less than %v", f, *s.exclusiveMaximum)
+	}
+	if s.multipleOf != nil {
+		q := f / *s.multipleOf
+		if math.Abs(q-math.Round(q)) > 1e-9 {
+			fail("multipleOf", "%v is not a multiple of %v", f, *s.multipleOf)
+		}
+	}
+}
+
+func (s *schemaNode) matchesType(n *Node) bool {
+	actual := schemaTypeOf(n)
+	for _, t := range s.types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaTypeOf returns the JSON Schema type name of a Node.  Numbers
+// without a fractional part are integers.
+func schemaTypeOf(n *Node) string {
+	switch n.GetType() {
+	case Null, Missing:
+		return "null"
+	case Bool:
+		return "boolean"
+	case Object:
+		return "object"
+	case Array:
+		return "array"
+	case Number:
+		f := n.AsFloat()
+		if f == math.Trunc(f) && !math.IsInf(f, 0) {
+			return "integer"
+		}
+		return "number"
+	default:
+		return "string"
+	}
+}
+
+// indexOfValue returns the index of the first element of list
+// that has the same JSON value as n, or -1.
+func indexOfValue(list []*Node, n *Node) int {
+	s := n.String()
+	for i, e := range list {
+		if e.String() == s {
+			return i
+		}
+	}
+	return -1
+}
+
+func nodesString(list []*Node) string {
+	s := make([]string, len(list))
+	for i, e := range list {
+		s[i] = e.String()
+	}
+	sort.Strings(s)
+	return "[" + strings.Join(s, ", ") + "]"
+}
@@ -0,0 +1,147 @@
+package jnode
+
+import (
+	"sort"
+)
+
+// InferOptions controls InferSchema.
+type InferOptions struct {
+	// RequiredRatio is the fraction of objects in which a field must
+	// appear for it to be required.  The default (0) means 1.0, so only
+	// fields present in every object are required.
+	RequiredRatio float64
+	// MaxEnumValues is the largest number of distinct strings that
+	// will be reported as an enum.  Enums are only inferred when each
+	// distinct value appears at least twice on average.  The default
+	// (0) means 5, and a negative value disables enum detection.
+	MaxEnumValues int
+}
+
+// InferSchema infers a JSON Schema (draft 2020-12) from one or more
+// sample Nodes.  A value with different types in different samples gets
+// a union type (e.g. ["string","null"]), and integers are widened to
+// numbers if any sample has a fractional part.  Object fields are
+// described under "properties" and array elements (of all samples)
+// under "items".
+func InferSchema(samples []*Node, opts InferOptions) *Node {
+	if opts.RequiredRatio <= 0 {
+		opts.RequiredRatio = 1
+	}
+	if opts.MaxEnumValues == 0 {
+		opts.MaxEnumValues = 5
+	}
+	s := newShape()
+	for _, n := range samples {
+		s.add(n, opts)
+	}
+	schema := NewObjectNode().Put("$schema", "https://json-schema.org/draft/2020-12/schema")
+	s.write(schema, opts)
+	return schema
+}
+
+// shape accumulates the types and structure of a set of values.
+type shape struct {
+	count   int
+	types   map[string]int
+	objects int
+	props   map[string]*shape
+	items   *shape
+	strs    map[string]int
+	nstrs   int
+}
+
+func newShape() *shape {
+	return &shape{types: make(map[string]int)}
+}
+
+func (s *shape) add(n *Node, opts InferOptions) {
+	s.count++
+	t := schemaTypeOf(n)
+	s.types[t]++
+	switch t {
+	case "object":
+		s.objects++
+		if s.props == nil {
+			s.props = make(map[string]*shape)
+		}
+		for k, v := range n.Entries() {
+			p := s.props[k]
+			if p == nil {
+				p = newShape()
+				s.props[k] = p
+			}
+			p.add(v, opts)
+		}
+	case "array":
+		if s.items == nil {
+			s.items = newShape()
+		}
+		for _, e := range n.Elements() {
+			s.items.add(e, opts)
+		}
+	case "string":
+		s.nstrs++
+		if s.strs == nil {
+			s.strs = make(map[string]int)
+		}
+		str := n.AsText()
+		if _, ok := s.strs[str]; ok || len(s.strs) <= opts.MaxEnumValues {
+			s.strs[str]++
+		}
+	}
+}
+
+func (s *shape) write(schema *Node, opts InferOptions) {
+	if s.count == 0 {
+		return
+	}
+	if s.types["integer"] > 0 && s.types["number"] > 0 {
+		s.types["number"] += s.types["integer"]
+		delete(s.types, "integer")
+	}
+	types := make([]string, 0, len(s.types))
+	for t := range s.types {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	if len(types) == 1 {
+		schema.Put("type", types[0])
+	} else {
+		schema.PutArray("type").Append(types)
+	}
+	if s.props != nil {
+		props := schema.PutObject("properties")
+		var required []string
+		for _, k := range sortedShapeKeys(s.props) {
+			p := s.props[k]
+			p.write(props.PutObject(k), opts)
+			if float64(p.count) >= opts.RequiredRatio*float64(s.objects) {
+				required = append(required, k)
+			}
+		}
+		if len(required) > 0 {
+			schema.PutArray("required").Append(required)
+		}
+	}
+	if s.items != nil && s.items.count > 0 {
+		s.items.write(schema.PutObject("items"), opts)
+	}
+	if len(types) == 1 && types[0] == "string" && opts.MaxEnumValues > 0 &&
+		len(s.strs) <= opts.MaxEnumValues && s.nstrs >= 2*len(s.strs) {
+		values := make([]string, 0, len(s.strs))
+		for v := range s.strs {
+			values = append(values, v)
+		}
+		sort.Strings(values)
+		schema.PutArray("enum").Append(values)
+	}
+}
+
+func sortedShapeKeys(m map[string]*shape) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,53 @@
+package jnode
+
+import (
+	"testing"
+)
+
+func TestInferSchema(t *testing.T) {
+	samples := []*Node{
+		mustJSON(t, `{"id":1,"name":"a","status":"open","score":1,"tags":["x"],"meta":{"a":1}}`),
+		mustJSON(t, `{"id":2,"name":"b","status":"closed","score":1.5,"tags":[],"meta":null}`),
+		mustJSON(t, `{"id":3,"name":"c","status":"open","score":2,"tags":["y",1]}`),
+		mustJSON(t, `{"id":4,"name":"d","status":"open","score":3}`),
+	}
+	schema := InferSchema(samples, InferOptions{})
+	expected := `{"$schema":"https://json-schema.org/draft/2020-12/schema",` +
+		`"properties":{` +
+		`"id":{"type":"integer"},` +
+		`"meta":{"properties":{"a":{"type":"integer"}},"required":["a"],"type":["null","object"]},` +
+		`"name":{"type":"string"},` +
+		`"score":{"type":"number"},` +
+		`"status":{"enum":["closed","open"],"type":"string"},` +
+		`"tags":{"items":{"type":["integer","string"]},"type":"array"}},` +
+		`"required":["id","name","score","status"],"type":"object"}`
+	if s := schema.String(); s != expected {
+		t.Error(s)
+	}
+	compiled, err := CompileSchema(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range samples {
+		if err := compiled.Validate(n); err != nil {
+			t.Error(n, err)
+		}
+	}
+	schema = InferSchema(samples, InferOptions{RequiredRatio: 0.5, MaxEnumValues: -1})
+	if s := schema.Path("required").String(); s != `["id","meta","name","score","status","tags"]` {
+		t.Error(s)
+	}
+	if !schema.Path("properties").Path("status").Path("enum").IsMissing() {
+		t.Error(schema)
+	}
+}
+
+func TestInferSchemaScalars(t *testing.T) {
+	schema := InferSchema([]*Node{NewNode("x"), NewNode(true), NullNode}, InferOptions{})
+	if s := schema.Path("type").String(); s != `["boolean","null","string"]` {
+		t.Error(s)
+	}
+	if s := InferSchema(nil, InferOptions{}).String(); s != `{"$schema":"https://json-schema.org/draft/2020-12/schema"}` {
+		t.Error(s)
+	}
+}
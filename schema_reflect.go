@@ -0,0 +1,429 @@
+package jnode
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	nodeType          = reflect.TypeOf(Node{})
+	jsonNumberType    = reflect.TypeOf(json.Number(""))
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// SchemaFor returns a JSON Schema (draft 2020-12) describing the JSON
+// encoding of a Go type, as produced by encoding/json.  It honors json
+// struct tags: fields tagged "-" are skipped, fields without omitempty
+// are required, and the string option makes a field a string.  Embedded
+// structs are flattened the way encoding/json does.  Pointers are
+// described by the type they point to, maps become objects with
+// additionalProperties, slices and arrays become arrays ([]byte is a
+// base64 string), and time.Time is a date-time string.  Pointers, maps
+// and slices also allow null (which encoding/json writes for nil),
+// except for fields with omitempty, which are omitted when nil.  Named struct
+// types other than t itself are placed under "$defs" and referenced
+// with "$ref", which allows recursive types.  Types with a custom
+// MarshalJSON method are unconstrained.
+//
+// A jsonschema struct tag annotates a field with a comma separated list
+// of keyword=value pairs, e.g.
+//
+//     Kind string `json:"kind" jsonschema:"description=The kind\, or type,enum=a,enum=b"`
+//     Port int    `json:"port" jsonschema:"minimum=1,maximum=65535,default=8080"`
+//
+// The keywords are title, description, format, pattern, enum (repeated
+// for each value), default, minimum, maximum, exclusiveMinimum,
+// exclusiveMaximum, multipleOf, minLength, maxLength, minItems and
+// maxItems, along with "required" and "optional" (without a value) to
+// override the default.  A literal comma in a value is written as "\,".
+// SchemaFor returns an error if a jsonschema tag is invalid.
+func SchemaFor(t reflect.Type) (*Node, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	schema := NewObjectNode().Put("$schema", "https://json-schema.org/draft/2020-12/schema")
+	g := &schemaGenerator{
+		root:       t,
+		rootSchema: schema,
+		defs:       make(map[string]*Node),
+		names:      make(map[reflect.Type]string),
+	}
+	g.describe(schema, t)
+	if g.err != nil {
+		return nil, g.err
+	}
+	if len(g.defs) > 0 {
+		defs := schema.PutObject("$defs")
+		for name, def := range g.defs {
+			defs.Put(name, def)
+		}
+	}
+	return schema, nil
+}
+
+// MustSchemaFor is like SchemaFor but panics if a jsonschema tag is
+// invalid.  It simplifies generating schemas for known types.
+func MustSchemaFor(t reflect.Type) *Node {
+	schema, err := SchemaFor(t)
+	if err != nil {
+		panic(err)
+	}
+	return schema
+}
+
+type schemaGenerator struct {
+	root       reflect.Type
+	rootSchema *Node
+	defs       map[string]*Node
+	names      map[reflect.Type]string
+	err        error
+}
+
+// describe adds the keywords that describe t to schema.
+func (g *schemaGenerator) describe(schema *Node, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == nodeType:
+		return
+	case t == timeType:
+		schema.Put("type", "string").Put("format", "date-time")
+		return
+	case t == jsonNumberType:
+		schema.Put("type", "number")
+		return
+	case implements(t, jsonMarshalerType):
+		return
+	case implements(t, textMarshalerType):
+		schema.Put("type", "string")
+		return
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		schema.Put("type", "boolean")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		schema.Put("type", "integer")
+	case reflect.Float32, reflect.Float64:
+		schema.Put("type", "number")
+	case reflect.String:
+		schema.Put("type", "string")
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 &&
+			!implements(t.Elem(), jsonMarshalerType) && !implements(t.Elem(), textMarshalerType) {
+			schema.Put("type", "string").Put("contentEncoding", "base64")
+			return
+		}
+		schema.Put("type", "array")
+		g.describeNullable(schema.PutObject("items"), t.Elem())
+		if t.Kind() == reflect.Array {
+			schema.Put("minItems", t.Len()).Put("maxItems", t.Len())
+		}
+	case reflect.Map:
+		schema.Put("type", "object")
+		g.describeNullable(schema.PutObject("additionalProperties"), t.Elem())
+	case reflect.Struct:
+		switch {
+		case schema == g.rootSchema || t.Name() == "":
+			g.describeStruct(schema, t)
+		case t == g.root:
+			schema.Put("$ref", "#")
+		default:
+			schema.Put("$ref", "#/$defs/"+pointerEscaper.Replace(g.define(t)))
+		}
+	}
+}
+
+// describeNullable describes t, allowing null if t is a pointer,
+// map or slice.
+func (g *schemaGenerator) describeNullable(schema *Node, t reflect.Type) {
+	g.describe(schema, t)
+	if nullable(t) {
+		allowNull(schema)
+	}
+}
+
+// nullable returns true if encoding/json writes null for a nil t.
+func nullable(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return true
+	case reflect.Map, reflect.Slice:
+		return !implements(t, jsonMarshalerType) && !implements(t, textMarshalerType)
+	}
+	return false
+}
+
+// allowNull changes schema to also accept null.
+func allowNull(schema *Node) {
+	switch typ := schema.Path("type"); {
+	case typ.GetType() == Text:
+		schema.PutArray("type").Append([]string{typ.AsText(), "null"})
+	case schema.Path("$ref").GetType() == Text:
+		ref := schema.Path("$ref").AsText()
+		schema.Remove("$ref")
+		anyOf := schema.PutArray("anyOf")
+		anyOf.AppendObject().Put("$ref", ref)
+		anyOf.AppendObject().Put("type", "null")
+	default:
+		return
+	}
+	if enum := schema.Path("enum"); enum.IsArray() {
+		enum.Append(nil)
+	}
+}
+
+// define adds a named struct type to $defs and returns its name.
+func (g *schemaGenerator) define(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, taken := g.defs[name]; taken {
+		name = strings.Replace(t.PkgPath(), "/", ".", -1) + "." + name
+	}
+	g.names[t] = name
+	def := NewObjectNode()
+	g.defs[name] = def
+	g.describeStruct(def, t)
+	return name
+}
+
+// schemaField is a field of a struct after flattening embedded
+// structs.
+type schemaField struct {
+	name     string
+	index    []int
+	typ      reflect.Type
+	tagged   bool
+	optional bool
+	quoted   bool
+	tag      string
+}
+
+func (g *schemaGenerator) describeStruct(schema *Node, t reflect.Type) {
+	schema.Put("type", "object")
+	props := schema.PutObject("properties")
+	var required []string
+	for _, f := range structFields(t) {
+		p := props.PutObject(f.name)
+		if f.quoted {
+			p.Put("type", "string")
+		} else {
+			g.describe(p, f.typ)
+		}
+		req, err := annotateSchema(p, f)
+		if err != nil && g.err == nil {
+			g.err = err
+		}
+		if req {
+			required = append(required, f.name)
+		}
+		if !f.optional && nullable(f.typ) {
+			allowNull(p)
+		}
+	}
+	if len(required) > 0 {
+		schema.PutArray("required").Append(required)
+	}
+	schema.Put("additionalProperties", false)
+}
+
+// structFields returns the fields encoding/json would use for a
+// struct type, in order.
+func structFields(t reflect.Type) []schemaField {
+	var fields []schemaField
+	visited := map[reflect.Type]bool{t: true}
+	var walk func(t reflect.Type, index []int)
+	walk = func(t reflect.Type, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			ft := sf.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if sf.PkgPath != "" && !(sf.Anonymous && ft.Kind() == reflect.Struct) {
+				continue
+			}
+			tag := sf.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts := tag, ""
+			if c := strings.IndexByte(tag, ','); c >= 0 {
+				name, opts = tag[:c], tag[c:]
+			}
+			idx := append(append([]int{}, index...), i)
+			if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+				if !visited[ft] {
+					visited[ft] = true
+					walk(ft, idx)
+				}
+				continue
+			}
+			if sf.PkgPath != "" {
+				continue
+			}
+			f := schemaField{
+				name:     name,
+				index:    idx,
+				typ:      sf.Type,
+				tagged:   name != "",
+				optional: strings.Contains(opts, ",omitempty"),
+				tag:      sf.Tag.Get("jsonschema"),
+			}
+			if f.name == "" {
+				f.name = sf.Name
+			}
+			if strings.Contains(opts, ",string") {
+				switch ft.Kind() {
+				case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+					reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+					reflect.Uintptr, reflect.Float32, reflect.Float64, reflect.String:
+					f.quoted = true
+				}
+			}
+			fields = append(fields, f)
+		}
+	}
+	walk(t, nil)
+	return dominantFields(fields)
+}
+
+// dominantFields applies the encoding/json rules for fields with
+// the same name: the shallowest wins, then a tagged field, and if
+// that is ambiguous all of the fields are dropped.
+func dominantFields(fields []schemaField) []schemaField {
+	byName := make(map[string][]schemaField)
+	var order []string
+	for _, f := range fields {
+		if _, ok := byName[f.name]; !ok {
+			order = append(order, f.name)
+		}
+		byName[f.name] = append(byName[f.name], f)
+	}
+	var result []schemaField
+	for _, name := range order {
+		fs := byName[name]
+		sort.SliceStable(fs, func(i, j int) bool {
+			if len(fs[i].index) != len(fs[j].index) {
+				return len(fs[i].index) < len(fs[j].index)
+			}
+			return fs[i].tagged && !fs[j].tagged
+		})
+		if len(fs) > 1 && len(fs[0].index) == len(fs[1].index) && fs[0].tagged == fs[1].tagged {
+			continue
+		}
+		result = append(result, fs[0])
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := result[i].index, result[j].index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return result
+}
+
+// annotateSchema applies a field's jsonschema tag to its schema and
+// returns true if the field is required.
+func annotateSchema(schema *Node, f schemaField) (bool, error) {
+	required := !f.optional
+	for _, kv := range splitSchemaTag(f.tag) {
+		key, value := kv, ""
+		if eq := strings.IndexByte(kv, '='); eq >= 0 {
+			key, value = kv[:eq], kv[eq+1:]
+		}
+		switch key {
+		case "required":
+			required = true
+		case "optional":
+			required = false
+		case "title", "description", "format", "pattern":
+			schema.Put(key, value)
+		case "enum":
+			if schema.Path("enum").IsMissing() {
+				schema.PutArray("enum")
+			}
+			v, err := tagValue(schema, f, value)
+			if err != nil {
+				return false, err
+			}
+			schema.Path("enum").Append(v)
+		case "default":
+			v, err := tagValue(schema, f, value)
+			if err != nil {
+				return false, err
+			}
+			schema.Put(key, v)
+		case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "multipleOf":
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return false, fmt.Errorf("invalid jsonschema tag on field %s: %s", f.name, kv)
+			}
+			schema.Put(key, v)
+		case "minLength", "maxLength", "minItems", "maxItems":
+			v, err := strconv.Atoi(value)
+			if err != nil {
+				return false, fmt.Errorf("invalid jsonschema tag on field %s: %s", f.name, kv)
+			}
+			schema.Put(key, v)
+		default:
+			return false, fmt.Errorf("invalid jsonschema tag on field %s: %s", f.name, kv)
+		}
+	}
+	return required, nil
+}
+
+// tagValue converts a value from a jsonschema tag to the field's type.
+func tagValue(schema *Node, f schemaField, value string) (interface{}, error) {
+	switch schema.Path("type").AsText() {
+	case "integer", "number", "boolean":
+		v := inferValue(value)
+		if _, ok := v.(string); ok {
+			return nil, fmt.Errorf("invalid jsonschema tag on field %s: %s is not a %s", f.name,
+				value, schema.Path("type").AsText())
+		}
+		return v, nil
+	default:
+		return value, nil
+	}
+}
+
+// splitSchemaTag splits a jsonschema tag on unescaped commas.
+func splitSchemaTag(tag string) []string {
+	if tag == "" {
+		return nil
+	}
+	var parts []string
+	var sb strings.Builder
+	for i := 0; i < len(tag); i++ {
+		switch {
+		case tag[i] == '\\' && i+1 < len(tag) && tag[i+1] == ',':
+			sb.WriteByte(',')
+			i++
+		case tag[i] == ',':
+			parts = append(parts, sb.String())
+			sb.Reset()
+		default:
+			sb.WriteByte(tag[i])
+		}
+	}
+	return append(parts, sb.String())
+}
+
+func implements(t, iface reflect.Type) bool {
+	return t.Implements(iface) || reflect.PtrTo(t).Implements(iface)
+}
@@ -0,0 +1,119 @@
+package jnode
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testSchemaBase struct {
+	ID      string    `json:"id"`
+	Created time.Time `json:"created,omitempty"`
+	Shadow  int       `json:"shadow"`
+}
+
+type testSchemaAddress struct {
+	City string `json:"city" jsonschema:"description=The city\\, town or village"`
+}
+
+type testSchemaPerson struct {
+	testSchemaBase
+	Name     string              `json:"name" jsonschema:"minLength=1,pattern=^[a-z]+$"`
+	Age      *int                `json:"age,omitempty" jsonschema:"minimum=0,maximum=150,default=18"`
+	Kind     string              `json:"kind" jsonschema:"enum=a,enum=b,optional"`
+	Level    int                 `json:"level,string"`
+	Tags     []string            `json:"tags"`
+	Data     []byte              `json:"data,omitempty"`
+	Pair     [2]float64          `json:"pair"`
+	Labels   map[string]int      `json:"labels,omitempty"`
+	Home     *testSchemaAddress  `json:"home"`
+	Work     testSchemaAddress   `json:"work"`
+	Friends  []*testSchemaPerson `json:"friends,omitempty"`
+	Extra    *Node               `json:"extra,omitempty"`
+	Shadow   string              `json:"shadow"`
+	Skip     string              `json:"-"`
+	Untagged bool
+	Inline   struct{ X int } `json:"inline"`
+	Mode     *string         `json:"mode" jsonschema:"enum=x"`
+	private  int
+}
+
+func TestSchemaFor(t *testing.T) {
+	schema, err := SchemaFor(reflect.TypeOf(&testSchemaPerson{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"$defs":{"testSchemaAddress":{"additionalProperties":false,` +
+		`"properties":{"city":{"description":"The city, town or village","type":"string"}},` +
+		`"required":["city"],"type":"object"}},` +
+		`"$schema":"https://json-schema.org/draft/2020-12/schema",` +
+		`"additionalProperties":false,` +
+		`"properties":{` +
+		`"Untagged":{"type":"boolean"},` +
+		`"age":{"default":18,"maximum":150,"minimum":0,"type":"integer"},` +
+		`"created":{"format":"date-time","type":"string"},` +
+		`"data":{"contentEncoding":"base64","type":"string"},` +
+		`"extra":{},` +
+		`"friends":{"items":{"anyOf":[{"$ref":"#"},{"type":"null"}]},"type":"array"},` +
+		`"home":{"anyOf":[{"$ref":"#/$defs/testSchemaAddress"},{"type":"null"}]},` +
+		`"id":{"type":"string"},` +
+		`"inline":{"additionalProperties":false,"properties":{"X":{"type":"integer"}},"required":["X"],"type":"object"},` +
+		`"kind":{"enum":["a","b"],"type":"string"},` +
+		`"labels":{"additionalProperties":{"type":"integer"},"type":"object"},` +
+		`"level":{"type":"string"},` +
+		`"mode":{"enum":["x",null],"type":["string","null"]},` +
+		`"name":{"minLength":1,"pattern":"^[a-z]+$","type":"string"},` +
+		`"pair":{"items":{"type":"number"},"maxItems":2,"minItems":2,"type":"array"},` +
+		`"shadow":{"type":"string"},` +
+		`"tags":{"items":{"type":"string"},"type":["array","null"]},` +
+		`"work":{"$ref":"#/$defs/testSchemaAddress"}},` +
+		`"required":["id","name","level","tags","pair","home","work","shadow","Untagged","inline","mode"],` +
+		`"type":"object"}`
+	if s := schema.String(); s != expected {
+		t.Error(s)
+	}
+	compiled, err := CompileSchema(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := mustJSON(t, `{"id":"1","name":"bob","level":"2","tags":[],"pair":[1,2],"home":{"city":"x"},
+	  "work":{"city":"y"},"shadow":"s","Untagged":true,"inline":{"X":1},"mode":"x","friends":[{"id":"2"},null]}`)
+	err = compiled.Validate(n)
+	errs, ok := err.(ValidationErrors)
+	if !ok || len(errs) != 1 || errs[0].InstancePath != "/friends/0" ||
+		errs[0].KeywordPath != "/properties/friends/items/anyOf" {
+		t.Fatal(err)
+	}
+	var p testSchemaPerson
+	p.Name, p.Kind, p.Friends = "bob", "a", []*testSchemaPerson{nil}
+	b, _ := json.Marshal(p)
+	if err := compiled.Validate(mustJSON(t, string(b))); err != nil {
+		t.Error(string(b), err)
+	}
+}
+
+func TestSchemaForScalars(t *testing.T) {
+	if s := MustSchemaFor(reflect.TypeOf(1.5)).Path("type").AsText(); s != "number" {
+		t.Error(s)
+	}
+	if s := MustSchemaFor(reflect.TypeOf(map[string]*int{})).Path("additionalProperties").String(); s != `{"type":["integer","null"]}` {
+		t.Error(s)
+	}
+	if s := MustSchemaFor(reflect.TypeOf(time.Time{})).Path("format").AsText(); s != "date-time" {
+		t.Error(s)
+	}
+	type bad struct {
+		A int `jsonschema:"minimum=x"`
+	}
+	if _, err := SchemaFor(reflect.TypeOf(bad{})); err == nil || err.Error() != "invalid jsonschema tag on field A: minimum=x" {
+		t.Error(err)
+	}
+	type badEnum struct {
+		A *int `json:"a" jsonschema:"enum=x"`
+	}
+	if _, err := SchemaFor(reflect.TypeOf(badEnum{})); err == nil {
+		t.Error("expected error")
+	}
+	assertPanic(t, func() { MustSchemaFor(reflect.TypeOf(bad{})) })
+}
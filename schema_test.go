@@ -0,0 +1,143 @@
+package jnode
+
+import (
+	"strings"
+	"testing"
+)
+
+const testSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "required": ["name", "age"],
+  "properties": {
+    "name": {"type": "string", "minLength": 1, "maxLength": 10, "pattern": "^[a-z]+$"},
+    "age": {"type": "integer", "minimum": 0, "exclusiveMaximum": 150},
+    "score": {"type": "number", "multipleOf": 0.5},
+    "kind": {"enum": ["a", "b", 1]},
+    "version": {"const": 2},
+    "tags": {"type": "array", "items": {"type": "string"}, "uniqueItems": true, "maxItems": 3},
+    "point": {"type": "array", "prefixItems": [{"type": "number"}, {"type": "number"}], "items": false},
+    "address": {"$ref": "#/$defs/address"},
+    "contact": {"oneOf": [{"required": ["email"]}, {"required": ["phone"]}]},
+    "id": {"anyOf": [{"type": "string"}, {"type": "integer"}]},
+    "extra": {"not": {"type": "null"}},
+    "child": {"$ref": "#"}
+  },
+  "patternProperties": {"^x-": {"type": "boolean"}},
+  "additionalProperties": false,
+  "$defs": {
+    "address": {
+      "type": "object",
+      "properties": {"city": {"type": ["string", "null"]}},
+      "minProperties": 1,
+      "allOf": [{"required": ["city"]}]
+    }
+  }
+}`
+
+func TestSchemaValid(t *testing.T) {
+	s, err := CompileSchema(mustJSON(t, testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := []string{
+		`{"name":"bob","age":30}`,
+		`{"name":"bob","age":30.0,"score":1.5,"kind":1,"version":2,"tags":["a","b"],"point":[1,2.5],
+		  "address":{"city":null},"contact":{"email":"x"},"id":3,"extra":0,"x-debug":true,
+		  "child":{"name":"amy","age":1}}`,
+	}
+	for _, v := range valid {
+		if err := s.Validate(mustJSON(t, v)); err != nil {
+			t.Error(v, err)
+		}
+	}
+}
+
+func TestSchemaInvalid(t *testing.T) {
+	s, err := CompileSchema(mustJSON(t, testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := mustJSON(t, `{"name":"Bob","age":150.5,"score":1.2,"kind":"c","version":3,
+	  "tags":["a","a","b","c"],"point":[1,"x",3],"address":{"city":1},"contact":{"email":"x","phone":"y"},
+	  "id":true,"extra":null,"x-debug":"yes","other":1,"child":{"name":"amy"}}`)
+	err = s.Validate(n)
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"/address/city: expected string or null, got integer",
+		"/age: expected integer, got number",
+		"/age: 150.5 is not less than 150",
+		"/child: missing required property \"age\"",
+		"/contact: value matches more than one schema (0, 1)",
+		"/extra: value must not match the schema",
+		"/id: value does not match any of the schemas",
+		"/kind: value \"c\" is not one of [\"a\", \"b\", 1]",
+		"(root): property \"other\" is not allowed",
+		"/name: \"Bob\" does not match pattern \"^[a-z]+$\"",
+		"/point/1: expected number, got string",
+		"/point/2: no value is allowed",
+		"/score: 1.2 is not a multiple of 0.5",
+		"/tags: array has 4 items, more than 3",
+		"/tags: items 0 and 1 are equal",
+		"/version: value 3 is not 2",
+		"/x-debug: expected boolean, got string",
+	}
+	if len(errs) != len(expected) {
+		t.Error(errs)
+	}
+	for _, e := range expected {
+		if !strings.Contains(err.Error(), e) {
+			t.Error("missing", e)
+		}
+	}
+	for _, e := range errs {
+		if e.InstancePath == "/address/city" && e.KeywordPath != "/$defs/address/properties/city/type" {
+			t.Error(e.KeywordPath)
+		}
+	}
+}
+
+func TestSchemaBoolean(t *testing.T) {
+	s, _ := CompileSchema(NewNode(true))
+	if err := s.Validate(NewNode(1)); err != nil {
+		t.Error(err)
+	}
+	s, _ = CompileSchema(NewNode(false))
+	if err := s.Validate(NewNode(1)); err == nil {
+		t.Error("false schema should fail")
+	}
+}
+
+func TestSchemaRecursion(t *testing.T) {
+	s, err := CompileSchema(mustJSON(t, `{"$defs":{"a":{"$ref":"#/$defs/a"}},"$ref":"#/$defs/a"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Validate(NewNode(1)); err == nil {
+		t.Error("infinite recursion should fail")
+	}
+}
+
+func TestCompileSchemaErrors(t *testing.T) {
+	bad := []string{
+		`1`,
+		`{"type":"thing"}`,
+		`{"required":"a"}`,
+		`{"pattern":"("}`,
+		`{"minimum":"1"}`,
+		`{"multipleOf":0}`,
+		`{"multipleOf":-2}`,
+		`{"$ref":"#/$defs/missing"}`,
+		`{"$ref":"other.json"}`,
+		`{"allOf":[]}`,
+		`{"properties":{"a":1}}`,
+	}
+	for _, b := range bad {
+		if _, err := CompileSchema(mustJSON(t, b)); err == nil {
+			t.Error(b)
+		}
+	}
+}
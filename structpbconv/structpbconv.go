@@ -0,0 +1,207 @@
+// Package structpbconv converts between jnode Nodes and the protobuf
+// well-known types google.protobuf.Struct, Value and ListValue, without
+// going through JSON.  It is a separate package so that only programs
+// that use it depend on the protobuf runtime.
+//
+// google.protobuf.Value holds all numbers as doubles, so by default
+// integers beyond ±2^53 (such as large int64 or uint64 values) are
+// rounded to the nearest float64, just as they would be by a JSON
+// round-trip.  Options.ExactIntegers makes this an error instead.
+package structpbconv
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/soluble-ai/go-jnode"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Options control conversion of Nodes to protobuf values.
+type Options struct {
+	// ExactIntegers makes integers that a float64 can't represent
+	// exactly an error instead of being rounded.
+	ExactIntegers bool
+}
+
+// ToValue converts n to a google.protobuf.Value.  Missing and null
+// become NullValue, Binary becomes a standard base64 string (as with
+// AsText; see jnode.BinaryCodec), and numbers become doubles.
+func ToValue(n *jnode.Node, opts Options) (*structpb.Value, error) {
+	return toValue(n, opts, nil)
+}
+
+// ToStruct converts an Object Node to a google.protobuf.Struct.
+func ToStruct(n *jnode.Node, opts Options) (*structpb.Struct, error) {
+	if !n.IsObject() {
+		return nil, fmt.Errorf("structpbconv: not an object")
+	}
+	return toStruct(n, opts, nil)
+}
+
+// ToListValue converts an Array Node to a google.protobuf.ListValue.
+func ToListValue(n *jnode.Node, opts Options) (*structpb.ListValue, error) {
+	if !n.IsArray() {
+		return nil, fmt.Errorf("structpbconv: not an array")
+	}
+	return toListValue(n, opts, nil)
+}
+
+// FromValue converts a google.protobuf.Value to a Node.  Numbers
+// become float64 values, as they do with jnode.FromJSON, and a nil
+// Value becomes jnode.NullNode.
+func FromValue(v *structpb.Value) *jnode.Node {
+	switch k := v.GetKind().(type) {
+	case *structpb.Value_BoolValue:
+		return jnode.NewNode(k.BoolValue)
+	case *structpb.Value_NumberValue:
+		return jnode.NewNode(k.NumberValue)
+	case *structpb.Value_StringValue:
+		return jnode.NewNode(k.StringValue)
+	case *structpb.Value_ListValue:
+		return FromListValue(k.ListValue)
+	case *structpb.Value_StructValue:
+		return FromStruct(k.StructValue)
+	default:
+		return jnode.NullNode
+	}
+}
+
+// FromStruct converts a google.protobuf.Struct to an Object Node.
+func FromStruct(s *structpb.Struct) *jnode.Node {
+	n := jnode.NewObjectNode()
+	for k, v := range s.GetFields() {
+		n.Put(k, FromValue(v))
+	}
+	return n
+}
+
+// FromListValue converts a google.protobuf.ListValue to an Array Node.
+func FromListValue(l *structpb.ListValue) *jnode.Node {
+	n := jnode.NewArrayNode()
+	for _, v := range l.GetValues() {
+		n.Append(FromValue(v))
+	}
+	return n
+}
+
+// The path is shared by the whole conversion, and is only used to
+// format errors.
+func toValue(n *jnode.Node, opts Options, path jnode.Path) (*structpb.Value, error) {
+	switch n.GetType() {
+	case jnode.Missing, jnode.Null:
+		return structpb.NewNullValue(), nil
+	case jnode.Bool:
+		return structpb.NewBoolValue(n.AsBool()), nil
+	case jnode.Text, jnode.Binary:
+		return structpb.NewStringValue(n.AsText()), nil
+	case jnode.Number:
+		return toNumber(n.Unwrap(), opts, path)
+	case jnode.Array:
+		l, err := toListValue(n, opts, path)
+		if err != nil {
+			return nil, err
+		}
+		return structpb.NewListValue(l), nil
+	case jnode.Object:
+		s, err := toStruct(n, opts, path)
+		if err != nil {
+			return nil, err
+		}
+		return structpb.NewStructValue(s), nil
+	default:
+		return nil, fmt.Errorf("structpbconv: %s: %T cannot be converted", path, n.Unwrap())
+	}
+}
+
+func toStruct(n *jnode.Node, opts Options, path jnode.Path) (*structpb.Struct, error) {
+	fields := make(map[string]*structpb.Value, n.Size())
+	for k, e := range n.Fields() {
+		v, err := toValue(e, opts, append(path, k))
+		if err != nil {
+			return nil, err
+		}
+		fields[k] = v
+	}
+	return &structpb.Struct{Fields: fields}, nil
+}
+
+func toListValue(n *jnode.Node, opts Options, path jnode.Path) (*structpb.ListValue, error) {
+	values := make([]*structpb.Value, n.Size())
+	for i, e := range n.Items() {
+		v, err := toValue(e, opts, append(path, i))
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return &structpb.ListValue{Values: values}, nil
+}
+
+func toNumber(value interface{}, opts Options, path jnode.Path) (*structpb.Value, error) {
+	switch v := value.(type) {
+	case float32:
+		return structpb.NewNumberValue(float64(v)), nil
+	case float64:
+		return structpb.NewNumberValue(v), nil
+	case int:
+		return toInt(int64(v), opts, path)
+	case int8:
+		return structpb.NewNumberValue(float64(v)), nil
+	case int16:
+		return structpb.NewNumberValue(float64(v)), nil
+	case int32:
+		return structpb.NewNumberValue(float64(v)), nil
+	case int64:
+		return toInt(v, opts, path)
+	case uint:
+		return toUint(uint64(v), opts, path)
+	case uint8:
+		return structpb.NewNumberValue(float64(v)), nil
+	case uint16:
+		return structpb.NewNumberValue(float64(v)), nil
+	case uint32:
+		return structpb.NewNumberValue(float64(v)), nil
+	case uint64:
+		return toUint(v, opts, path)
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return toInt(i, opts, path)
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return toUint(u, opts, path)
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("structpbconv: %s: invalid number %s", path, v)
+		}
+		if opts.ExactIntegers && !strings.ContainsAny(string(v), ".eE") {
+			return nil, inexactError(path, string(v))
+		}
+		return structpb.NewNumberValue(f), nil
+	default:
+		return nil, fmt.Errorf("structpbconv: %s: %T cannot be converted", path, value)
+	}
+}
+
+func toInt(i int64, opts Options, path jnode.Path) (*structpb.Value, error) {
+	f := float64(i)
+	if opts.ExactIntegers && (f >= 0x1p63 || int64(f) != i) {
+		return nil, inexactError(path, strconv.FormatInt(i, 10))
+	}
+	return structpb.NewNumberValue(f), nil
+}
+
+func toUint(u uint64, opts Options, path jnode.Path) (*structpb.Value, error) {
+	f := float64(u)
+	if opts.ExactIntegers && (f >= 0x1p64 || uint64(f) != u) {
+		return nil, inexactError(path, strconv.FormatUint(u, 10))
+	}
+	return structpb.NewNumberValue(f), nil
+}
+
+func inexactError(path jnode.Path, number string) error {
+	return fmt.Errorf("structpbconv: %s: %s can't be represented exactly as a double", path, number)
+}
@@ -0,0 +1,126 @@
+package structpbconv
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/soluble-ai/go-jnode"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func mustJSON(t *testing.T, s string) *jnode.Node {
+	t.Helper()
+	n, err := jnode.FromJSON([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestToStruct(t *testing.T) {
+	n := mustJSON(t, `{"a":[1,"x",true,null,{"b":2.5}],"c":{}}`)
+	n.Put("bin", []byte{1, 2, 3})
+	n.Put("u", uint64(42))
+	s, err := ToStruct(n, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := s.AsMap()
+	if m["bin"] != "AQID" || m["u"] != 42.0 {
+		t.Error(m)
+	}
+	a := m["a"].([]interface{})
+	if len(a) != 5 || a[0] != 1.0 || a[1] != "x" || a[2] != true || a[3] != nil || a[4].(map[string]interface{})["b"] != 2.5 {
+		t.Error(a)
+	}
+	if _, err := ToStruct(n.Path("a"), Options{}); err == nil {
+		t.Error("array converted to struct")
+	}
+	l, err := ToListValue(n.Path("a"), Options{})
+	if err != nil || len(l.GetValues()) != 5 {
+		t.Error(l, err)
+	}
+	if _, err := ToListValue(n, Options{}); err == nil {
+		t.Error("object converted to list")
+	}
+	v, err := ToValue(jnode.MissingNode, Options{})
+	if _, ok := v.GetKind().(*structpb.Value_NullValue); err != nil || !ok {
+		t.Error(v, err)
+	}
+	v, err = ToValue(jnode.NewNode(""), Options{})
+	if _, ok := v.GetKind().(*structpb.Value_StringValue); err != nil || !ok || v.GetStringValue() != "" {
+		t.Error(v, err)
+	}
+}
+
+func TestToStructExactIntegers(t *testing.T) {
+	n := jnode.NewObjectNode()
+	n.PutObject("a").PutArray("b").Append(int64(1) << 53).Append(int64(1)<<53 + 1)
+	v, err := ToValue(n, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := v.GetStructValue().GetFields()["a"].GetStructValue().GetFields()["b"].GetListValue().GetValues()
+	if b[0].GetNumberValue() != 1<<53 || b[1].GetNumberValue() != 1<<53 {
+		t.Error(b)
+	}
+	_, err = ToValue(n, Options{ExactIntegers: true})
+	if err == nil || !strings.Contains(err.Error(), "/a/b/1: 9007199254740993") {
+		t.Error(err)
+	}
+	exact := []interface{}{int64(1) << 60, int64(math.MinInt64), uint64(1) << 63, int64(-1) << 53}
+	for _, x := range exact {
+		if _, err := ToValue(jnode.NewNode(x), Options{ExactIntegers: true}); err != nil {
+			t.Error(err)
+		}
+	}
+	inexact := []interface{}{int64(math.MaxInt64), uint64(math.MaxUint64), -(int64(1)<<53 + 1)}
+	for _, x := range inexact {
+		if _, err := ToValue(jnode.NewNode(x), Options{ExactIntegers: true}); err == nil {
+			t.Error(x)
+		}
+	}
+}
+
+func TestFromStruct(t *testing.T) {
+	s, err := structpb.NewStruct(map[string]interface{}{
+		"a": []interface{}{1, "x", true, nil, map[string]interface{}{"b": 2.5}},
+		"c": map[string]interface{}{},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := FromStruct(s)
+	if s := n.String(); s != `{"a":[1,"x",true,null,{"b":2.5}],"c":{}}` {
+		t.Error(s)
+	}
+	if _, ok := n.Path("a").Get(0).Unwrap().(float64); !ok {
+		t.Error(n.Path("a").Get(0).Unwrap())
+	}
+	if !n.Path("a").Get(4).IsObject() || !n.Path("c").IsObject() {
+		t.Error(n)
+	}
+	if s := FromListValue(s.GetFields()["a"].GetListValue()).String(); s != `[1,"x",true,null,{"b":2.5}]` {
+		t.Error(s)
+	}
+	if s := FromValue(structpb.NewListValue(&structpb.ListValue{})).String(); s != `[]` {
+		t.Error(s)
+	}
+	l, _ := structpb.NewList([]interface{}{[]interface{}{1.5}, []interface{}{}, nil})
+	if s := FromListValue(l).String(); s != `[[1.5],[],null]` {
+		t.Error(s)
+	}
+	if s := FromValue(structpb.NewStringValue("x")).String(); s != `"x"` {
+		t.Error(s)
+	}
+	if !FromValue(nil).IsNull() || !FromValue(&structpb.Value{}).IsNull() {
+		t.Error("nil value is not null")
+	}
+	if s := FromStruct(nil).String(); s != `{}` {
+		t.Error(s)
+	}
+	if s := FromListValue(nil).String(); s != `[]` {
+		t.Error(s)
+	}
+}
@@ -0,0 +1,888 @@
+package jnode
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// TOMLSyntaxError describes a syntax error in a TOML document.
+type TOMLSyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (e *TOMLSyntaxError) Error() string {
+	return fmt.Sprintf("toml: line %d: %s", e.Line, e.Msg)
+}
+
+// FromTOML creates an Object Node from a TOML (v1.0.0) document.
+// Tables and inline tables become Objects, and arrays and arrays of
+// tables become Arrays.  Integers become int64 and floats float64.
+// Datetimes become Text in RFC 3339 form, with a "T" between the date
+// and time and an upper case "Z": offset datetimes such as
+// "1979-05-27T07:32:00-08:00" keep their offset, and local datetimes
+// ("1979-05-27T07:32:00"), local dates ("1979-05-27") and local times
+// ("07:32:00") are kept as written.
+//
+// Syntax errors are returned as a *TOMLSyntaxError.
+func FromTOML(data []byte) (*Node, error) {
+	if !utf8.Valid(data) {
+		return MissingNode, &TOMLSyntaxError{Line: 1, Msg: "document is not valid UTF-8"}
+	}
+	p := &tomlParser{s: string(data), line: 1}
+	root := &tomlTable{m: make(map[string]interface{}), explicit: true}
+	if err := p.parse(root); err != nil {
+		return MissingNode, err
+	}
+	return &Node{root.m}, nil
+}
+
+// tomlTable tracks how each table was defined, since TOML doesn't
+// allow a table to be defined twice.
+type tomlTable struct {
+	m map[string]interface{}
+	// explicit is set for tables defined by a [header]
+	explicit bool
+	// dotted is set for tables defined by dotted keys
+	dotted bool
+	// frozen is set for inline tables, and for values that aren't tables
+	frozen bool
+	// tables is set for arrays of tables
+	tables   []*tomlTable
+	children map[string]*tomlTable
+}
+
+func (t *tomlTable) child(key string) *tomlTable {
+	if t.children == nil {
+		t.children = make(map[string]*tomlTable)
+	}
+	c := t.children[key]
+	if c == nil {
+		if _, ok := t.m[key]; ok {
+			c = &tomlTable{frozen: true}
+		} else {
+			c = &tomlTable{}
+		}
+		t.children[key] = c
+	}
+	return c
+}
+
+type tomlParser struct {
+	s    string
+	pos  int
+	line int
+}
+
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	return &TOMLSyntaxError{Line: p.line, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *tomlParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *tomlParser) unexpected(expected string) error {
+	if p.eof() {
+		return p.errorf("expected %s, found end of document", expected)
+	}
+	r, _ := utf8.DecodeRuneInString(p.s[p.pos:])
+	return p.errorf("expected %s, found %q", expected, r)
+}
+
+func (p *tomlParser) skipSpace() {
+	for p.peek() == ' ' || p.peek() == '\t' {
+		p.pos++
+	}
+}
+
+// skipComment skips a comment up to (but not including) the end of the line.
+func (p *tomlParser) skipComment() error {
+	if p.peek() != '#' {
+		return nil
+	}
+	for !p.eof() && p.peek() != '\n' && !strings.HasPrefix(p.s[p.pos:], "\r\n") {
+		if c := p.peek(); c < 0x20 && c != '\t' || c == 0x7f {
+			return p.errorf("control character 0x%02x in comment", c)
+		}
+		p.pos++
+	}
+	return nil
+}
+
+// newline consumes a newline, returning false if there isn't one.
+func (p *tomlParser) newline() bool {
+	switch {
+	case p.peek() == '\n':
+		p.pos++
+	case strings.HasPrefix(p.s[p.pos:], "\r\n"):
+		p.pos += 2
+	default:
+		return false
+	}
+	p.line++
+	return true
+}
+
+// skipBlank skips whitespace, comments and newlines.
+func (p *tomlParser) skipBlank() error {
+	for {
+		p.skipSpace()
+		if err := p.skipComment(); err != nil {
+			return err
+		}
+		if !p.newline() {
+			return nil
+		}
+	}
+}
+
+// endLine consumes the rest of a line after a key/value pair or header.
+func (p *tomlParser) endLine() error {
+	p.skipSpace()
+	if err := p.skipComment(); err != nil {
+		return err
+	}
+	if !p.eof() && !p.newline() {
+		return p.unexpected("end of line")
+	}
+	return nil
+}
+
+func (p *tomlParser) parse(root *tomlTable) error {
+	current := root
+	for {
+		if err := p.skipBlank(); err != nil {
+			return err
+		}
+		if p.eof() {
+			return nil
+		}
+		var err error
+		if p.peek() == '[' {
+			current, err = p.parseHeader(root)
+		} else {
+			err = p.parseKeyValue(current)
+		}
+		if err != nil {
+			return err
+		}
+		if err := p.endLine(); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *tomlParser) parseHeader(root *tomlTable) (*tomlTable, error) {
+	p.pos++
+	array := p.peek() == '['
+	if array {
+		p.pos++
+	}
+	keys, err := p.parseKey()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != ']' || array && !strings.HasPrefix(p.s[p.pos:], "]]") {
+		return nil, p.unexpected("]")
+	}
+	p.pos++
+	if array {
+		p.pos++
+	}
+	t := root
+	for _, k := range keys[:len(keys)-1] {
+		if t, err = p.subTable(t, k, false); err != nil {
+			return nil, err
+		}
+	}
+	k := keys[len(keys)-1]
+	c := t.child(k)
+	if array {
+		if c.tables == nil {
+			if c.frozen || c.m != nil {
+				return nil, p.errorf("%s is already defined", k)
+			}
+			a := make([]interface{}, 0)
+			t.m[k] = &a
+		}
+		a := t.m[k].(*[]interface{})
+		e := &tomlTable{m: make(map[string]interface{}), explicit: true}
+		*a = append(*a, e.m)
+		c.tables = append(c.tables, e)
+		return e, nil
+	}
+	if c.m == nil && !c.frozen && c.tables == nil {
+		c.m = make(map[string]interface{})
+		t.m[k] = c.m
+	} else if c.explicit || c.dotted || c.frozen || c.tables != nil {
+		return nil, p.errorf("table %s is already defined", k)
+	}
+	c.explicit = true
+	return c, nil
+}
+
+// subTable returns the table named by a key component, creating it if
+// necessary.  For dotted keys the table can't have been defined any
+// other way.
+func (p *tomlParser) subTable(t *tomlTable, k string, dotted bool) (*tomlTable, error) {
+	c := t.child(k)
+	switch {
+	case c.tables != nil && !dotted:
+		return c.tables[len(c.tables)-1], nil
+	case c.frozen || c.tables != nil:
+		return nil, p.errorf("%s is already defined as a value", k)
+	case c.m == nil:
+		c.m = make(map[string]interface{})
+		c.dotted = dotted
+		t.m[k] = c.m
+	case dotted && !c.dotted:
+		return nil, p.errorf("table %s is already defined", k)
+	}
+	return c, nil
+}
+
+func (p *tomlParser) parseKeyValue(t *tomlTable) error {
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	if p.peek() != '=' {
+		return p.unexpected("=")
+	}
+	p.pos++
+	p.skipSpace()
+	v, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+	for _, k := range keys[:len(keys)-1] {
+		if t, err = p.subTable(t, k, true); err != nil {
+			return err
+		}
+	}
+	k := keys[len(keys)-1]
+	if _, ok := t.m[k]; ok {
+		return p.errorf("%s is already defined", k)
+	}
+	t.m[k] = v
+	t.child(k)
+	return nil
+}
+
+func isTOMLBare(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// parseKey parses a (possibly dotted) key and the whitespace after it.
+func (p *tomlParser) parseKey() ([]string, error) {
+	var keys []string
+	for {
+		p.skipSpace()
+		var k string
+		var err error
+		switch c := p.peek(); {
+		case c == '"':
+			k, err = p.parseBasicString()
+		case c == '\'':
+			k, err = p.parseLiteralString()
+		case isTOMLBare(c):
+			start := p.pos
+			for isTOMLBare(p.peek()) {
+				p.pos++
+			}
+			k = p.s[start:p.pos]
+		default:
+			err = p.unexpected("a key")
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+		p.skipSpace()
+		if p.peek() != '.' {
+			return keys, nil
+		}
+		p.pos++
+	}
+}
+
+func (p *tomlParser) parseValue() (interface{}, error) {
+	rest := p.s[p.pos:]
+	switch c := p.peek(); {
+	case strings.HasPrefix(rest, `"""`):
+		return p.parseMultilineString('"')
+	case c == '"':
+		return p.parseBasicString()
+	case strings.HasPrefix(rest, "'''"):
+		return p.parseMultilineString('\'')
+	case c == '\'':
+		return p.parseLiteralString()
+	case c == '[':
+		return p.parseArray()
+	case c == '{':
+		return p.parseInlineTable()
+	case strings.HasPrefix(rest, "true") && !isTOMLBare(byteAt(rest, 4)):
+		p.pos += 4
+		return true, nil
+	case strings.HasPrefix(rest, "false") && !isTOMLBare(byteAt(rest, 5)):
+		p.pos += 5
+		return false, nil
+	default:
+		return p.parseScalar()
+	}
+}
+
+func byteAt(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return 0
+}
+
+var (
+	tomlIntPattern      = regexp.MustCompile(`^[+-]?(0|[1-9](_?[0-9])*)$`)
+	tomlPrefixedPattern = regexp.MustCompile(`^0(x[0-9A-Fa-f](_?[0-9A-Fa-f])*|o[0-7](_?[0-7])*|b[01](_?[01])*)$`)
+	tomlFloatPattern    = regexp.MustCompile(`^[+-]?(0|[1-9](_?[0-9])*)(\.[0-9](_?[0-9])*)?([eE][+-]?[0-9](_?[0-9])*)?$`)
+	tomlDatePattern     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	tomlTimePattern     = regexp.MustCompile(`^\d{2}:\d{2}:\d{2}(\.\d+)?$`)
+	tomlDateTimePattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})[Tt ](\d{2}:\d{2}:\d{2}(\.\d+)?)([Zz]|[+-]\d{2}:\d{2})?$`)
+)
+
+// parseScalar parses a number or datetime.
+func (p *tomlParser) parseScalar() (interface{}, error) {
+	start := p.pos
+	for c := p.peek(); isTOMLBare(c) || c == '+' || c == '.' || c == ':'; c = p.peek() {
+		p.pos++
+	}
+	// a space may separate the date and time
+	if tomlDatePattern.MatchString(p.s[start:p.pos]) && p.peek() == ' ' &&
+		len(p.s) > p.pos+3 && p.s[p.pos+3] == ':' {
+		p.pos++
+		for c := p.peek(); isTOMLBare(c) || c == '+' || c == '.' || c == ':'; c = p.peek() {
+			p.pos++
+		}
+	}
+	s := p.s[start:p.pos]
+	if s == "" {
+		return nil, p.unexpected("a value")
+	}
+	switch s {
+	case "inf", "+inf":
+		return math.Inf(1), nil
+	case "-inf":
+		return math.Inf(-1), nil
+	case "nan", "+nan", "-nan":
+		return math.NaN(), nil
+	}
+	switch {
+	case tomlIntPattern.MatchString(s):
+		i, err := strconv.ParseInt(strings.ReplaceAll(s, "_", ""), 10, 64)
+		if err != nil {
+			return nil, p.errorf("integer %s is out of range", s)
+		}
+		return i, nil
+	case tomlPrefixedPattern.MatchString(s):
+		base := map[byte]int{'x': 16, 'o': 8, 'b': 2}[s[1]]
+		i, err := strconv.ParseInt(strings.ReplaceAll(s[2:], "_", ""), base, 64)
+		if err != nil {
+			return nil, p.errorf("integer %s is out of range", s)
+		}
+		return i, nil
+	case tomlFloatPattern.MatchString(s):
+		f, err := strconv.ParseFloat(strings.ReplaceAll(s, "_", ""), 64)
+		if err != nil {
+			return nil, p.errorf("float %s is out of range", s)
+		}
+		return f, nil
+	}
+	if dt, ok := tomlDatetime(s); ok {
+		return dt, nil
+	}
+	return nil, p.errorf("invalid value %s", s)
+}
+
+// tomlDatetime returns the normalized form of a TOML offset datetime,
+// local datetime, local date or local time.
+func tomlDatetime(s string) (string, bool) {
+	var layout string
+	switch {
+	case tomlDatePattern.MatchString(s):
+		layout = "2006-01-02"
+	case tomlTimePattern.MatchString(s):
+		layout = "15:04:05"
+	default:
+		m := tomlDateTimePattern.FindStringSubmatch(s)
+		if m == nil {
+			return "", false
+		}
+		s = m[1] + "T" + m[2] + strings.ToUpper(m[4])
+		layout = "2006-01-02T15:04:05"
+		if m[4] != "" {
+			layout += "Z07:00"
+		}
+	}
+	if _, err := time.Parse(layout, s); err != nil {
+		return "", false
+	}
+	return s, true
+}
+
+// checkChar rejects the control characters that TOML strings can't contain.
+func (p *tomlParser) checkChar(c byte) error {
+	if c < 0x20 && c != '\t' || c == 0x7f {
+		return p.errorf("control character 0x%02x in string", c)
+	}
+	return nil
+}
+
+func (p *tomlParser) parseBasicString() (string, error) {
+	p.pos++
+	var sb strings.Builder
+	for {
+		if p.eof() || p.peek() == '\n' || p.peek() == '\r' {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.peek()
+		switch c {
+		case '"':
+			p.pos++
+			return sb.String(), nil
+		case '\\':
+			if err := p.parseEscape(&sb); err != nil {
+				return "", err
+			}
+		default:
+			if err := p.checkChar(c); err != nil {
+				return "", err
+			}
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+func (p *tomlParser) parseEscape(sb *strings.Builder) error {
+	p.pos++
+	c := p.peek()
+	p.pos++
+	switch c {
+	case 'b':
+		sb.WriteByte('\b')
+	case 't':
+		sb.WriteByte('\t')
+	case 'n':
+		sb.WriteByte('\n')
+	case 'f':
+		sb.WriteByte('\f')
+	case 'r':
+		sb.WriteByte('\r')
+	case '"', '\\':
+		sb.WriteByte(c)
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+		if p.pos+n > len(p.s) {
+			return p.errorf("invalid escape")
+		}
+		r, err := strconv.ParseUint(p.s[p.pos:p.pos+n], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(r)) {
+			return p.errorf("invalid escape \\%c%s", c, p.s[p.pos:p.pos+n])
+		}
+		sb.WriteRune(rune(r))
+		p.pos += n
+	default:
+		return p.errorf("invalid escape \\%c", c)
+	}
+	return nil
+}
+
+func (p *tomlParser) parseLiteralString() (string, error) {
+	p.pos++
+	start := p.pos
+	for p.peek() != '\'' {
+		if p.eof() || p.peek() == '\n' || p.peek() == '\r' {
+			return "", p.errorf("unterminated string")
+		}
+		if err := p.checkChar(p.peek()); err != nil {
+			return "", err
+		}
+		p.pos++
+	}
+	p.pos++
+	return p.s[start : p.pos-1], nil
+}
+
+func (p *tomlParser) parseMultilineString(quote byte) (string, error) {
+	p.pos += 3
+	p.newline()
+	var sb strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.peek()
+		switch {
+		case c == quote && strings.HasPrefix(p.s[p.pos:], strings.Repeat(string(quote), 3)):
+			// up to two quotes can precede the closing delimiter
+			n := 3
+			for n < 6 && byteAt(p.s, p.pos+n) == quote {
+				n++
+			}
+			if n == 6 {
+				return "", p.errorf("too many quotes in string")
+			}
+			sb.WriteString(strings.Repeat(string(quote), n-3))
+			p.pos += n
+			return sb.String(), nil
+		case p.newline():
+			sb.WriteByte('\n')
+		case c == '\\' && quote == '"':
+			// a backslash at the end of a line trims the following whitespace
+			i := p.pos + 1
+			for byteAt(p.s, i) == ' ' || byteAt(p.s, i) == '\t' {
+				i++
+			}
+			if byteAt(p.s, i) == '\n' || strings.HasPrefix(p.s[i:], "\r\n") {
+				p.pos = i
+				for {
+					if p.peek() == ' ' || p.peek() == '\t' {
+						p.pos++
+					} else if !p.newline() {
+						break
+					}
+				}
+			} else if err := p.parseEscape(&sb); err != nil {
+				return "", err
+			}
+		default:
+			if err := p.checkChar(c); err != nil {
+				return "", err
+			}
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+func (p *tomlParser) parseArray() (interface{}, error) {
+	p.pos++
+	a := make([]interface{}, 0)
+	for {
+		if err := p.skipBlank(); err != nil {
+			return nil, err
+		}
+		if p.peek() == ']' {
+			p.pos++
+			return &a, nil
+		}
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+		if err := p.skipBlank(); err != nil {
+			return nil, err
+		}
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+		default:
+			return nil, p.unexpected(", or ]")
+		}
+	}
+}
+
+func (p *tomlParser) parseInlineTable() (interface{}, error) {
+	p.pos++
+	t := &tomlTable{m: make(map[string]interface{})}
+	p.skipSpace()
+	if p.peek() == '}' {
+		p.pos++
+		return t.m, nil
+	}
+	for {
+		if err := p.parseKeyValue(t); err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return t.m, nil
+		default:
+			return nil, p.unexpected(", or }")
+		}
+	}
+}
+
+// TOMLOptions control how ToTOML writes a Node.
+type TOMLOptions struct {
+	// Datetimes writes Text values that are TOML datetimes, as
+	// produced by FromTOML, as datetimes rather than strings.
+	Datetimes bool
+	// Homogeneous rejects arrays that mix value types, which TOML
+	// versions before 1.0.0 don't allow.
+	Homogeneous bool
+}
+
+// ToTOML writes an Object Node as a TOML (v1.0.0) document, with keys
+// in sorted order.  Nested Objects are written as tables and Arrays
+// of Objects as arrays of tables, except inside arrays and inline
+// tables.  Binary values are written as base64 strings.  Null values,
+// and integers outside the int64 range, can't be represented in TOML
+// and are reported as errors.
+func (n *Node) ToTOML(opts TOMLOptions) ([]byte, error) {
+	m, ok := n.value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("toml: only Object nodes can be written as documents")
+	}
+	w := &tomlWriter{opts: opts}
+	if err := w.table(nil, m, false); err != nil {
+		return nil, err
+	}
+	return []byte(w.sb.String()), nil
+}
+
+type tomlWriter struct {
+	sb   strings.Builder
+	opts TOMLOptions
+}
+
+func isTOMLTableArray(v interface{}) bool {
+	a, ok := v.(*[]interface{})
+	if !ok || len(*a) == 0 {
+		return false
+	}
+	for _, e := range *a {
+		if _, ok := e.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func tomlKey(k string) string {
+	for i := 0; i < len(k); i++ {
+		if !isTOMLBare(k[i]) {
+			return tomlQuote(k)
+		}
+	}
+	if k == "" {
+		return `""`
+	}
+	return k
+}
+
+func tomlPath(path []string) string {
+	keys := make([]string, len(path))
+	for i, k := range path {
+		keys[i] = tomlKey(k)
+	}
+	return strings.Join(keys, ".")
+}
+
+func tomlQuote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&sb, `\u%04X`, r)
+			} else {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+func (w *tomlWriter) table(path []string, m map[string]interface{}, element bool) error {
+	keys := sortedKeys(m)
+	var values, tables []string
+	for _, k := range keys {
+		if _, ok := m[k].(map[string]interface{}); ok || isTOMLTableArray(m[k]) {
+			tables = append(tables, k)
+		} else {
+			values = append(values, k)
+		}
+	}
+	// tables that only contain tables are defined implicitly
+	if len(path) > 0 && (element || len(values) > 0 || len(tables) == 0) {
+		if w.sb.Len() > 0 {
+			w.sb.WriteByte('\n')
+		}
+		if element {
+			fmt.Fprintf(&w.sb, "[[%s]]\n", tomlPath(path))
+		} else {
+			fmt.Fprintf(&w.sb, "[%s]\n", tomlPath(path))
+		}
+	}
+	for _, k := range values {
+		s, err := w.value(append(path, k), m[k])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&w.sb, "%s = %s\n", tomlKey(k), s)
+	}
+	for _, k := range tables {
+		p := append(path[:len(path):len(path)], k)
+		if sub, ok := m[k].(map[string]interface{}); ok {
+			if err := w.table(p, sub, false); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, e := range *m[k].(*[]interface{}) {
+			if err := w.table(p, e.(map[string]interface{}), true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// tomlType returns the name of the TOML type a value is written as.
+func (w *tomlWriter) tomlType(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		if _, ok := tomlDatetime(v); ok && w.opts.Datetimes {
+			return "datetime"
+		}
+		return "string"
+	case []byte:
+		return "string"
+	case float32, float64:
+		return "float"
+	case json.Number:
+		if _, err := v.Int64(); err != nil {
+			return "float"
+		}
+		return "integer"
+	case *[]interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "table"
+	case bool:
+		return "boolean"
+	default:
+		return "integer"
+	}
+}
+
+func (w *tomlWriter) value(path []string, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", fmt.Errorf("toml: %s is null, which can't be represented", tomlPath(path))
+	case string:
+		if dt, ok := tomlDatetime(v); ok && w.opts.Datetimes {
+			return dt, nil
+		}
+		return tomlQuote(v), nil
+	case []byte:
+		return tomlQuote(encodeBinary(v)), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float32:
+		return tomlFloat(float64(v), 32), nil
+	case float64:
+		return tomlFloat(v, 64), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return strconv.FormatInt(i, 10), nil
+		}
+		f, err := v.Float64()
+		if err != nil || strings.Trim(string(v), "-0123456789") == "" {
+			return "", fmt.Errorf("toml: %s is out of range", tomlPath(path))
+		}
+		return tomlFloat(f, 64), nil
+	case *[]interface{}:
+		elems := make([]string, len(*v))
+		for i, e := range *v {
+			if w.opts.Homogeneous && i > 0 && w.tomlType(e) != w.tomlType((*v)[0]) {
+				return "", fmt.Errorf("toml: %s mixes %s and %s values", tomlPath(path),
+					w.tomlType((*v)[0]), w.tomlType(e))
+			}
+			s, err := w.value(append(path, strconv.Itoa(i)), e)
+			if err != nil {
+				return "", err
+			}
+			elems[i] = s
+		}
+		return "[" + strings.Join(elems, ", ") + "]", nil
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return "{}", nil
+		}
+		var fields []string
+		for _, k := range sortedKeys(v) {
+			s, err := w.value(append(path, k), v[k])
+			if err != nil {
+				return "", err
+			}
+			fields = append(fields, tomlKey(k)+" = "+s)
+		}
+		return "{ " + strings.Join(fields, ", ") + " }", nil
+	}
+	i, u, err := integerValue(&Node{value}, Number)
+	switch {
+	case err != nil:
+		return "", fmt.Errorf("toml: %T at %s can't be represented", value, tomlPath(path))
+	case u > math.MaxInt64:
+		return "", fmt.Errorf("toml: %s is out of range", tomlPath(path))
+	case u != 0:
+		return strconv.FormatUint(u, 10), nil
+	default:
+		return strconv.FormatInt(i, 10), nil
+	}
+}
+
+func tomlFloat(f float64, bits int) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+	s := formatFloat(f, bits)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
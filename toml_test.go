@@ -0,0 +1,249 @@
+package jnode
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+const tomlExample = `# This is a TOML document
+title = "TOML Example"
+
+[owner]
+name = "Tom Preston-Werner"
+dob = 1979-05-27 07:32:00-08:00
+
+[database]
+enabled = true
+ports = [ 8000, 8001, 8002 ]
+data = [ ["delta", "phi"], [3.14] ]
+temp_targets = { cpu = 79.5, case = 72.0 }
+
+[servers]
+
+[servers.alpha]
+ip = "10.0.0.1"
+role = "frontend"
+
+[servers.beta]
+ip = "10.0.0.2"
+role = "backend"
+
+[[products]]
+name = "Hammer"
+sku = 738594937
+
+[[products]]  # empty table within the array
+
+[[products]]
+name = "Nail"
+sku = 284758393
+color = "gray"
+`
+
+func TestFromTOML(t *testing.T) {
+	n, err := FromTOML([]byte(tomlExample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := n.Path("owner").Path("dob").AsText(); s != "1979-05-27T07:32:00-08:00" {
+		t.Error(s)
+	}
+	if s := n.Path("database").String(); s != `{"data":[["delta","phi"],[3.14]],"enabled":true,"ports":[8000,8001,8002],"temp_targets":{"case":72,"cpu":79.5}}` {
+		t.Error(s)
+	}
+	if s := n.Path("servers").Path("beta").Path("role").AsText(); s != "backend" {
+		t.Error(s)
+	}
+	if s := n.Path("products").String(); s != `[{"name":"Hammer","sku":738594937},{},{"color":"gray","name":"Nail","sku":284758393}]` {
+		t.Error(s)
+	}
+	if _, ok := n.Path("products").Get(0).Path("sku").Unwrap().(int64); !ok {
+		t.Error(n.Path("products").Get(0).Path("sku").Unwrap())
+	}
+}
+
+func TestTOMLValues(t *testing.T) {
+	tests := map[string]string{
+		`a = "tab\there \u00e9 \U0001F600"`:       `"tab\there é 😀"`,
+		`a = 'C:\Users\x'`:                        `"C:\\Users\\x"`,
+		"a = \"\"\"\nline1\nline2\"\"\"":          `"line1\nline2"`,
+		"a = \"\"\"\\\n   joined \\\n  up\"\"\"":  `"joined up"`,
+		`a = """quote "" here"""""`:               `"quote \"\" here\"\""`,
+		"a = '''\nraw \\n'''":                     `"raw \\n"`,
+		`a = 1_000`:                               `1000`,
+		`a = 0xdead_beef`:                         `3735928559`,
+		`a = 0o755`:                               `493`,
+		`a = 0b1101`:                              `13`,
+		`a = -17`:                                 `-17`,
+		`a = 6.626e-34`:                           `6.626e-34`,
+		`a = -0.01`:                               `-0.01`,
+		`a = 1979-05-27`:                          `"1979-05-27"`,
+		`a = 07:32:00.999`:                        `"07:32:00.999"`,
+		`a = 1979-05-27t07:32:00z`:                `"1979-05-27T07:32:00Z"`,
+		`a = 1979-05-27T00:32:00.5`:               `"1979-05-27T00:32:00.5"`,
+		"a = [\n  1, # one\n  2,\n]":              `[1,2]`,
+		`a = { x.y = 1, z = [] }`:                 `{"x":{"y":1},"z":[]}`,
+		`"quoted key".'lit' = true`:               `true`,
+		"a.b.c = 1\na.b.d = 2":                    `{"c":1,"d":2}`,
+		"[a.b]\nc = 1\n[a]\nd = 2":                `{"c":1}`,
+		"a.x = 1\n[a.b]\nc = 1":                   `{"c":1}`,
+		"[[a.b]]\nx = 1\n[a.b.c]\ny = 2\n[[a.b]]": `[{"c":{"y":2},"x":1},{}]`,
+		"\r\na = 1\r\n":                           `1`,
+	}
+	for in, want := range tests {
+		n, err := FromTOML([]byte(in))
+		if err != nil {
+			t.Errorf("%q: %v", in, err)
+			continue
+		}
+		v := n.Path("a")
+		if strings.HasPrefix(in, `"quoted`) {
+			v = n.Path("quoted key").Path("lit")
+		} else if v.Path("b").IsObject() || v.Path("b").IsArray() {
+			v = v.Path("b")
+		}
+		if s := v.String(); s != want {
+			t.Errorf("%q: %s", in, s)
+		}
+	}
+	n, _ := FromTOML([]byte("a = inf\nb = -inf\nc = nan"))
+	if !math.IsInf(n.Path("a").AsFloat(), 1) || !math.IsInf(n.Path("b").AsFloat(), -1) || !math.IsNaN(n.Path("c").AsFloat()) {
+		t.Error(n.Entries())
+	}
+}
+
+func TestFromTOMLErrors(t *testing.T) {
+	tests := map[string]int{
+		"a = 1\na = 2":            2,
+		"[a]\n[a]":                2,
+		"a = {}\n[a]":             2,
+		"a = {b = 1}\na.c = 2":    2,
+		"a.b = 1\n[a.b]":          2,
+		"[a.b.c]\n[a]\nb.d = 1":   3,
+		"a = [1]\n[[a]]":          2,
+		"[[a]]\n[a]":              2,
+		"a = 01":                  1,
+		"a = 1__0":                1,
+		"a = 9223372036854775808": 1,
+		"a = 1979-13-01":          1,
+		`a = "\x"`:                1,
+		"a = \"unterminated":      1,
+		"a = { b = 1, }":          1,
+		"a = { b = 1\n}":          1,
+		"a = 1 b = 2":             1,
+		"\n\na =":                 3,
+		"[a":                      1,
+		"[[a]":                    1,
+		"a = 'line\nbreak'":       1,
+		"a = \"\"\"x\"\"\"\"\"\"": 1,
+		"# bad \x01 comment":      1,
+		"= 1":                     1,
+		"a = truee":               1,
+	}
+	for in, line := range tests {
+		_, err := FromTOML([]byte(in))
+		e, ok := err.(*TOMLSyntaxError)
+		if !ok {
+			t.Errorf("%q: %v", in, err)
+		} else if e.Line != line {
+			t.Errorf("%q: %v", in, e)
+		}
+	}
+}
+
+func TestToTOML(t *testing.T) {
+	n, err := FromTOML([]byte(tomlExample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := n.ToTOML(TOMLOptions{Datetimes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `title = "TOML Example"
+
+[database]
+data = [["delta", "phi"], [3.14]]
+enabled = true
+ports = [8000, 8001, 8002]
+
+[database.temp_targets]
+case = 72.0
+cpu = 79.5
+
+[owner]
+dob = 1979-05-27T07:32:00-08:00
+name = "Tom Preston-Werner"
+
+[[products]]
+name = "Hammer"
+sku = 738594937
+
+[[products]]
+
+[[products]]
+color = "gray"
+name = "Nail"
+sku = 284758393
+
+[servers.alpha]
+ip = "10.0.0.1"
+role = "frontend"
+
+[servers.beta]
+ip = "10.0.0.2"
+role = "backend"
+`
+	if string(data) != expected {
+		t.Error(string(data))
+	}
+	m, err := FromTOML(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.String() != n.String() {
+		t.Error(m)
+	}
+	data, _ = n.Path("owner").ToTOML(TOMLOptions{})
+	if s := string(data); !strings.Contains(s, `dob = "1979-05-27T07:32:00-08:00"`) {
+		t.Error(s)
+	}
+}
+
+func TestToTOMLValues(t *testing.T) {
+	n := NewObjectNode().Put("a b", "x\"\n\x01").Put("f", 1.0).Put("u", uint64(7)).
+		Put("bin", []byte{1}).Put("", 1)
+	n.PutObject("e")
+	n.PutArray("mixed").Append(1).Append(NewObjectNode().Put("k", "v"))
+	data, err := n.ToTOML(TOMLOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `"" = 1
+"a b" = "x\"\n\u0001"
+bin = "AQ=="
+f = 1.0
+mixed = [1, { k = "v" }]
+u = 7
+
+[e]
+`
+	if string(data) != expected {
+		t.Error(string(data))
+	}
+	if _, err := n.ToTOML(TOMLOptions{Homogeneous: true}); err == nil || !strings.Contains(err.Error(), "mixed mixes integer and table") {
+		t.Error(err)
+	}
+	n = NewObjectNode()
+	n.PutObject("a").PutArray("b").Append(1).Append(nil)
+	if _, err := n.ToTOML(TOMLOptions{}); err == nil || err.Error() != "toml: a.b.1 is null, which can't be represented" {
+		t.Error(err)
+	}
+	if _, err := NewObjectNode().Put("u", uint64(math.MaxUint64)).ToTOML(TOMLOptions{}); err == nil {
+		t.Error("expected range error")
+	}
+	if _, err := NewArrayNode().ToTOML(TOMLOptions{}); err == nil {
+		t.Error("expected error for an array")
+	}
+}
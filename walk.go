@@ -0,0 +1,222 @@
+package jnode
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Path is the location of a Node within a document.  Each element
+// is either a string (an object field name) or an int (an array index.)
+type Path []interface{}
+
+// Field returns a new Path that extends p with a field name.
+func (p Path) Field(name string) Path {
+	return p.append(name)
+}
+
+// Index returns a new Path that extends p with an array index.
+func (p Path) Index(i int) Path {
+	return p.append(i)
+}
+
+func (p Path) append(e interface{}) Path {
+	q := make(Path, len(p)+1)
+	copy(q, p)
+	q[len(p)] = e
+	return q
+}
+
+// Pointer returns the path as a JSON Pointer (RFC 6901), e.g.
+// "/a/0/b~1c".
+func (p Path) Pointer() string {
+	tokens := make([]string, len(p))
+	for i, e := range p {
+		switch e := e.(type) {
+		case int:
+			tokens[i] = strconv.Itoa(e)
+		default:
+			tokens[i] = e.(string)
+		}
+	}
+	return formatPointer(tokens)
+}
+
+// JSONPath returns the path as a JSONPath expression, e.g.
+// "$.a[0]['b c']".
+func (p Path) JSONPath() string {
+	var sb strings.Builder
+	sb.WriteByte('$')
+	for _, e := range p {
+		switch e := e.(type) {
+		case int:
+			sb.WriteByte('[')
+			sb.WriteString(strconv.Itoa(e))
+			sb.WriteByte(']')
+		default:
+			name := e.(string)
+			if isIdentifier(name) {
+				sb.WriteByte('.')
+				sb.WriteString(name)
+			} else {
+				sb.WriteString("['")
+				sb.WriteString(jsonPathEscaper.Replace(name))
+				sb.WriteString("']")
+			}
+		}
+	}
+	return sb.String()
+}
+
+// String returns the path as a JSON Pointer.
+func (p Path) String() string {
+	return p.Pointer()
+}
+
+// Find returns the Node at the path within n, or MissingNode.
+func (p Path) Find(n *Node) *Node {
+	for _, e := range p {
+		switch e := e.(type) {
+		case int:
+			n = n.Get(e)
+		default:
+			n = n.Path(e.(string))
+		}
+	}
+	return n
+}
+
+var jsonPathEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+func isIdentifier(s string) bool {
+	for i, r := range s {
+		if !(r == '_' || r == '$' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') ||
+			(i > 0 && r >= '0' && r <= '9')) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// WalkAction is returned by the functions passed to Walk
+// and Transform to control the walk.
+type WalkAction int
+
+const (
+	// WalkContinue continues the walk.
+	WalkContinue WalkAction = iota
+	// WalkSkip skips the children of the current Node (when walking in
+	// pre-order.)
+	WalkSkip
+	// WalkStop ends the walk.
+	WalkStop
+	// WalkDelete removes the current Node from its parent (Transform
+	// only.)
+	WalkDelete
+)
+
+// Walk calls fn for n and each of its descendants in pre-order,
+// i.e. a parent before its children.  Object fields are visited in
+// sorted order.
+func Walk(n *Node, fn func(path Path, n *Node) WalkAction) {
+	walk(n, Path{}, fn, false)
+}
+
+// WalkPostOrder calls fn for n and each of its descendants in
+// post-order, i.e. children before their parent.
+func WalkPostOrder(n *Node, fn func(path Path, n *Node) WalkAction) {
+	walk(n, Path{}, fn, true)
+}
+
+// walk returns false if the walk was stopped.
+func walk(n *Node, path Path, fn func(Path, *Node) WalkAction, post bool) bool {
+	if !post {
+		switch fn(path, n) {
+		case WalkStop:
+			return false
+		case WalkSkip:
+			return true
+		}
+	}
+	switch n.GetType() {
+	case Object:
+		entries := n.Entries()
+		for _, k := range sortedKeys(n.ToMap()) {
+			if !walk(entries[k], path.Field(k), fn, post) {
+				return false
+			}
+		}
+	case Array:
+		for i, e := range n.Elements() {
+			if !walk(e, path.Index(i), fn, post) {
+				return false
+			}
+		}
+	}
+	if post && fn(path, n) == WalkStop {
+		return false
+	}
+	return true
+}
+
+// Transform walks n in pre-order like Walk, and lets fn replace or
+// delete Nodes in place.  fn returns the Node to use in place of the
+// current Node (which may be the Node itself, and nil means null) and
+// an action.  The children of the replacement are walked next unless
+// the action is WalkSkip.  WalkDelete removes the Node from its parent
+// object or array; elements after a deleted array element move down,
+// and are visited with their new index.  Transform returns the
+// (possibly replaced) root, or MissingNode if the root was deleted.
+func Transform(n *Node, fn func(path Path, n *Node) (*Node, WalkAction)) *Node {
+	r, _ := transform(n, Path{}, fn)
+	if r == nil {
+		return MissingNode
+	}
+	return r
+}
+
+// transform returns the replacement for n (nil if n was deleted),
+// and false if the walk was stopped.
+func transform(n *Node, path Path, fn func(Path, *Node) (*Node, WalkAction)) (*Node, bool) {
+	r, action := fn(path, n)
+	if r == nil {
+		r = NullNode
+	}
+	switch action {
+	case WalkDelete:
+		return nil, true
+	case WalkStop:
+		return r, false
+	case WalkSkip:
+		return r, true
+	}
+	switch r.GetType() {
+	case Object:
+		m := r.ToMap()
+		for _, k := range sortedKeys(m) {
+			c, ok := transform(&Node{m[k]}, path.Field(k), fn)
+			if c == nil {
+				delete(m, k)
+			} else {
+				m[k] = c.value
+			}
+			if !ok {
+				return r, false
+			}
+		}
+	case Array:
+		a := r.toSlicePtr()
+		for i := 0; i < len(*a); i++ {
+			c, ok := transform(&Node{(*a)[i]}, path.Index(i), fn)
+			if c == nil {
+				*a = append((*a)[:i], (*a)[i+1:]...)
+				i--
+			} else {
+				(*a)[i] = c.value
+			}
+			if !ok {
+				return r, false
+			}
+		}
+	}
+	return r, true
+}
@@ -0,0 +1,86 @@
+package jnode
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPath(t *testing.T) {
+	p := Path{}.Field("a").Index(0).Field("b/c").Field("d e").Field("it's")
+	if s := p.Pointer(); s != "/a/0/b~1c/d e/it's" {
+		t.Error(s)
+	}
+	if s := p.JSONPath(); s != `$.a[0]['b/c']['d e']['it\'s']` {
+		t.Error(s)
+	}
+	if s := (Path{}).JSONPath(); s != "$" {
+		t.Error(s)
+	}
+	n := mustJSON(t, `{"a":[{"b":1}]}`)
+	if (Path{"a", 0, "b"}).Find(n).AsInt() != 1 || !(Path{"a", 1}).Find(n).IsMissing() {
+		t.Error("find failed")
+	}
+}
+
+func TestWalk(t *testing.T) {
+	n := mustJSON(t, `{"b":[1,{"c":2}],"a":{"x":true},"d":"s"}`)
+	var visits []string
+	Walk(n, func(path Path, n *Node) WalkAction {
+		visits = append(visits, path.Pointer())
+		if path.Pointer() == "/a" {
+			return WalkSkip
+		}
+		if path.Pointer() == "/b/1/c" {
+			return WalkStop
+		}
+		return WalkContinue
+	})
+	if s := strings.Join(visits, " "); s != " /a /b /b/0 /b/1 /b/1/c" {
+		t.Error(s)
+	}
+	visits = nil
+	WalkPostOrder(n, func(path Path, n *Node) WalkAction {
+		visits = append(visits, path.JSONPath())
+		return WalkContinue
+	})
+	if s := strings.Join(visits, " "); s != "$.a.x $.a $.b[0] $.b[1].c $.b[1] $.b $.d $" {
+		t.Error(s)
+	}
+}
+
+func TestTransform(t *testing.T) {
+	n := mustJSON(t, `{"a":[1,"secret",2,"secret"],"b":{"password":"x","user":"y"},"c":{"keep":"secret"}}`)
+	var paths []string
+	r := Transform(n, func(path Path, n *Node) (*Node, WalkAction) {
+		paths = append(paths, path.Pointer())
+		switch {
+		case path.Pointer() == "/c":
+			return n, WalkSkip
+		case n.AsText() == "secret":
+			return nil, WalkDelete
+		case len(path) > 0 && path[len(path)-1] == "password":
+			return NewNode("***"), WalkContinue
+		case n.IsNumber():
+			return NewNode(n.AsInt() * 10), WalkContinue
+		}
+		return n, WalkContinue
+	})
+	if r != n {
+		t.Error("root should not be replaced")
+	}
+	if s := n.String(); s != `{"a":[10,20],"b":{"password":"***","user":"y"},"c":{"keep":"secret"}}` {
+		t.Error(s)
+	}
+	if s := strings.Join(paths, " "); s != " /a /a/0 /a/1 /a/1 /a/2 /b /b/password /b/user /c" {
+		t.Error(s)
+	}
+	r = Transform(n, func(path Path, n *Node) (*Node, WalkAction) {
+		return NewNode("replaced"), WalkStop
+	})
+	if r.AsText() != "replaced" {
+		t.Error(r)
+	}
+	if !Transform(n, func(path Path, n *Node) (*Node, WalkAction) { return n, WalkDelete }).IsMissing() {
+		t.Error("deleted root should be missing")
+	}
+}
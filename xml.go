@@ -0,0 +1,438 @@
+package jnode
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// XMLConvention selects how XML is mapped onto Nodes.
+type XMLConvention int
+
+const (
+	// XMLAttributes maps an element to an Object with attributes as
+	// "@name" fields, child elements as fields and text as a "#text"
+	// field, except that an element with only text becomes Text and
+	// an empty element becomes null.  The root element is the only
+	// field of the resulting Object.
+	XMLAttributes XMLConvention = iota
+	// XMLBadgerFish maps every element to an Object, with attributes
+	// as "@name" fields, namespace declarations in an "@xmlns" Object
+	// (the default namespace is its "$" field), text as a "$" field and
+	// child elements as fields.  The root element is the only field of
+	// the resulting Object.
+	XMLBadgerFish
+	// XMLParker maps elements with child elements to Objects and other
+	// elements to their text, converting numbers and booleans, or null
+	// if they're empty.  Attributes and text mixed with elements are
+	// dropped, and the root element becomes the resulting Node.
+	XMLParker
+)
+
+func (c XMLConvention) String() string {
+	switch c {
+	case XMLAttributes:
+		return "attributes"
+	case XMLBadgerFish:
+		return "badgerfish"
+	case XMLParker:
+		return "parker"
+	default:
+		return fmt.Sprintf("XMLConvention(%d)", int(c))
+	}
+}
+
+// XMLOptions control the conversion between XML and Nodes.
+type XMLOptions struct {
+	Convention XMLConvention
+	// ForceArray lists element names (as they appear in the Node) that
+	// always become Arrays, even when they occur once.
+	ForceArray []string
+	// StripNamespaces drops namespace prefixes from element and
+	// attribute names, and drops namespace declarations.
+	StripNamespaces bool
+	// Namespaces maps namespace URIs to the prefixes to use for them
+	// ("" for none), so names don't depend on the prefixes a document
+	// happens to use.  Declarations of these namespaces are dropped
+	// when reading, and added to the root element when writing.
+	Namespaces map[string]string
+	// Root is the name of the root element for the Parker convention
+	// when writing XML.  The default is "root".
+	Root string
+	// Indent, if set, indents nested elements when writing XML.
+	Indent string
+}
+
+// FromXML converts an XML document into a Node using the convention
+// in opts.  Text is trimmed of leading and trailing whitespace, and
+// comments and processing instructions are ignored.  When an element
+// mixes text and child elements, its text is concatenated.
+func FromXML(data []byte, opts XMLOptions) (*Node, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	r := &xmlReader{opts: opts, forced: make(map[string]bool)}
+	for _, name := range opts.ForceArray {
+		r.forced[name] = true
+	}
+	v, err := r.read(d)
+	if err != nil {
+		return MissingNode, err
+	}
+	return &Node{v}, nil
+}
+
+type xmlReader struct {
+	opts   XMLOptions
+	forced map[string]bool
+}
+
+// xmlElement accumulates an element while it is read.
+type xmlElement struct {
+	raw      xml.Name
+	name     string
+	attrs    map[string]interface{}
+	xmlns    map[string]interface{}
+	scope    map[string]string
+	children map[string][]interface{}
+	text     strings.Builder
+}
+
+func (r *xmlReader) read(d *xml.Decoder) (interface{}, error) {
+	var stack []*xmlElement
+	var root interface{}
+	rootName := ""
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			if rootName == "" {
+				return nil, errors.New("xml: no root element")
+			}
+			if len(stack) > 0 {
+				return nil, fmt.Errorf("xml: element <%s> is not closed", stack[len(stack)-1].name)
+			}
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if len(stack) == 0 && rootName != "" {
+				return nil, errors.New("xml: more than one root element")
+			}
+			scope := map[string]string{}
+			if len(stack) > 0 {
+				scope = stack[len(stack)-1].scope
+			}
+			e := r.start(t, scope)
+			stack = append(stack, e)
+			if len(stack) == 1 {
+				rootName = e.name
+			}
+		case xml.EndElement:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("xml: unexpected </%s>", xmlRawName(t.Name))
+			}
+			e := stack[len(stack)-1]
+			if t.Name != e.raw {
+				return nil, fmt.Errorf("xml: element <%s> closed by </%s>", xmlRawName(e.raw), xmlRawName(t.Name))
+			}
+			stack = stack[:len(stack)-1]
+			v := r.value(e)
+			if len(stack) == 0 {
+				root = v
+			} else {
+				p := stack[len(stack)-1]
+				p.children[e.name] = append(p.children[e.name], v)
+			}
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(t)
+			} else if len(bytes.TrimSpace(t)) > 0 {
+				return nil, errors.New("xml: text outside of the root element")
+			}
+		}
+	}
+	if r.opts.Convention == XMLParker {
+		return root, nil
+	}
+	return map[string]interface{}{rootName: root}, nil
+}
+
+func xmlRawName(n xml.Name) string {
+	if n.Space != "" {
+		return n.Space + ":" + n.Local
+	}
+	return n.Local
+}
+
+func (r *xmlReader) start(t xml.StartElement, parent map[string]string) *xmlElement {
+	e := &xmlElement{
+		raw:      t.Name,
+		scope:    parent,
+		attrs:    make(map[string]interface{}),
+		xmlns:    make(map[string]interface{}),
+		children: make(map[string][]interface{}),
+	}
+	// namespace declarations apply to the element's own name
+	copied := false
+	for _, a := range t.Attr {
+		if a.Name.Space == "xmlns" || a.Name.Space == "" && a.Name.Local == "xmlns" {
+			if !copied {
+				e.scope = make(map[string]string, len(parent)+1)
+				for k, v := range parent {
+					e.scope[k] = v
+				}
+				copied = true
+			}
+			prefix := a.Name.Local
+			if a.Name.Space == "" {
+				prefix = ""
+			}
+			e.scope[prefix] = a.Value
+			if _, mapped := r.opts.Namespaces[a.Value]; r.opts.StripNamespaces || mapped {
+				continue
+			}
+			if r.opts.Convention == XMLBadgerFish {
+				if prefix == "" {
+					prefix = "$"
+				}
+				e.xmlns[prefix] = a.Value
+			} else {
+				e.attrs["@"+xmlRawName(a.Name)] = a.Value
+			}
+			continue
+		}
+		e.attrs["@"+r.name(a.Name, e.scope, false)] = a.Value
+	}
+	e.name = r.name(t.Name, e.scope, true)
+	return e
+}
+
+// name converts an element or attribute name.  Unprefixed attributes
+// aren't in the default namespace.
+func (r *xmlReader) name(n xml.Name, scope map[string]string, element bool) string {
+	if r.opts.StripNamespaces {
+		return n.Local
+	}
+	if n.Space != "" || element {
+		if uri, ok := scope[n.Space]; ok {
+			if prefix, ok := r.opts.Namespaces[uri]; ok {
+				return xmlRawName(xml.Name{Space: prefix, Local: n.Local})
+			}
+		}
+	}
+	return xmlRawName(n)
+}
+
+func (r *xmlReader) value(e *xmlElement) interface{} {
+	text := strings.TrimSpace(e.text.String())
+	m := make(map[string]interface{}, len(e.children))
+	for name, values := range e.children {
+		if len(values) == 1 && !r.forced[name] {
+			m[name] = values[0]
+		} else {
+			a := values
+			m[name] = &a
+		}
+	}
+	switch r.opts.Convention {
+	case XMLParker:
+		if len(m) > 0 {
+			return m
+		}
+		if text == "" {
+			return nil
+		}
+		return inferValue(text)
+	case XMLBadgerFish:
+		if text != "" {
+			m["$"] = text
+		}
+		if len(e.xmlns) > 0 {
+			m["@xmlns"] = e.xmlns
+		}
+	default:
+		if len(m) == 0 && len(e.attrs) == 0 {
+			if text == "" {
+				return nil
+			}
+			return text
+		}
+		if text != "" {
+			m["#text"] = text
+		}
+	}
+	for k, v := range e.attrs {
+		m[k] = v
+	}
+	return m
+}
+
+// ToXML converts a Node to XML using the convention in opts.  For the
+// XMLAttributes and XMLBadgerFish conventions the Node must be an
+// Object with one field, the root element.  Fields are written in
+// sorted order, with Arrays becoming repeated elements.
+func (n *Node) ToXML(opts XMLOptions) ([]byte, error) {
+	w := &xmlWriter{opts: opts}
+	name := opts.Root
+	if name == "" {
+		name = "root"
+	}
+	value := n.value
+	if opts.Convention != XMLParker {
+		m, ok := n.value.(map[string]interface{})
+		if !ok || len(m) != 1 {
+			return nil, fmt.Errorf("xml: the %s convention requires an Object with one field", opts.Convention)
+		}
+		for name, value = range m {
+		}
+	}
+	if a, ok := value.(*[]interface{}); ok && len(*a) != 1 {
+		return nil, fmt.Errorf("xml: root %s must be a single element", name)
+	}
+	if err := w.element(name, value, 0, true); err != nil {
+		return nil, err
+	}
+	if opts.Indent != "" {
+		w.buf.WriteByte('\n')
+	}
+	return w.buf.Bytes(), nil
+}
+
+type xmlWriter struct {
+	buf  bytes.Buffer
+	opts XMLOptions
+}
+
+// isXMLName returns true if s can be used as an element or attribute name.
+func isXMLName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		letter := r == '_' || r == ':' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= 0xc0
+		if !letter && (i == 0 || !(r == '-' || r == '.' || r >= '0' && r <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *xmlWriter) newline(depth int) {
+	if w.opts.Indent != "" {
+		w.buf.WriteByte('\n')
+		w.buf.WriteString(strings.Repeat(w.opts.Indent, depth))
+	}
+}
+
+func (w *xmlWriter) text(s string) {
+	xml.EscapeText(&w.buf, []byte(s))
+}
+
+func (w *xmlWriter) element(name string, value interface{}, depth int, root bool) error {
+	if !isXMLName(name) {
+		return fmt.Errorf("xml: %q is not a valid element name", name)
+	}
+	if a, ok := value.(*[]interface{}); ok {
+		for i, e := range *a {
+			if _, nested := e.(*[]interface{}); nested {
+				return fmt.Errorf("xml: %s contains a nested array", name)
+			}
+			if i > 0 {
+				w.newline(depth)
+			}
+			if err := w.element(name, e, depth, root); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	attrs := make(map[string]string)
+	if root {
+		for uri, prefix := range w.opts.Namespaces {
+			if prefix == "" {
+				attrs["xmlns"] = uri
+			} else {
+				attrs["xmlns:"+prefix] = uri
+			}
+		}
+	}
+	var text string
+	var children map[string]interface{}
+	switch v := value.(type) {
+	case nil:
+	case map[string]interface{}:
+		if w.opts.Convention == XMLParker {
+			children = v
+			break
+		}
+		textKey := "#text"
+		if w.opts.Convention == XMLBadgerFish {
+			textKey = "$"
+		}
+		children = make(map[string]interface{}, len(v))
+		for k, e := range v {
+			switch {
+			case k == textKey:
+				text = (&Node{e}).AsText()
+			case k == "@xmlns" && w.opts.Convention == XMLBadgerFish:
+				ns, ok := e.(map[string]interface{})
+				if !ok {
+					return fmt.Errorf("xml: @xmlns of %s must be an Object", name)
+				}
+				for prefix, uri := range ns {
+					if prefix == "$" {
+						attrs["xmlns"] = (&Node{uri}).AsText()
+					} else {
+						attrs["xmlns:"+prefix] = (&Node{uri}).AsText()
+					}
+				}
+			case strings.HasPrefix(k, "@"):
+				if (&Node{e}).IsContainer() {
+					return fmt.Errorf("xml: attribute %s of %s must be a scalar", k, name)
+				}
+				attrs[k[1:]] = (&Node{e}).AsText()
+			default:
+				children[k] = e
+			}
+		}
+	case *[]interface{}:
+	default:
+		text = (&Node{v}).AsText()
+	}
+	w.buf.WriteString("<" + name)
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !isXMLName(k) {
+			return fmt.Errorf("xml: %q is not a valid attribute name", k)
+		}
+		w.buf.WriteString(" " + k + `="`)
+		w.text(attrs[k])
+		w.buf.WriteByte('"')
+	}
+	if text == "" && len(children) == 0 {
+		w.buf.WriteString("/>")
+		return nil
+	}
+	w.buf.WriteByte('>')
+	w.text(text)
+	for _, k := range sortedKeys(children) {
+		w.newline(depth + 1)
+		if err := w.element(k, children[k], depth+1, false); err != nil {
+			return err
+		}
+	}
+	if len(children) > 0 {
+		w.newline(depth)
+	}
+	w.buf.WriteString("</" + name + ">")
+	return nil
+}
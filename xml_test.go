@@ -0,0 +1,167 @@
+package jnode
+
+import (
+	"strings"
+	"testing"
+)
+
+const xmlFeed = `<?xml version="1.0"?>
+<!-- a feed -->
+<rss version="2.0">
+  <channel>
+    <title>News &amp; views</title>
+    <item id="1"><title>One</title><count>3</count></item>
+    <empty/>
+  </channel>
+</rss>`
+
+func TestFromXMLAttributes(t *testing.T) {
+	n, err := FromXML([]byte(xmlFeed), XMLOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := n.String(); s != `{"rss":{"@version":"2.0","channel":{"empty":null,"item":{"@id":"1","count":"3","title":"One"},"title":"News \u0026 views"}}}` {
+		t.Error(s)
+	}
+	n, err = FromXML([]byte(xmlFeed), XMLOptions{ForceArray: []string{"item"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := n.Path("rss").Path("channel").Path("item").String(); s != `[{"@id":"1","count":"3","title":"One"}]` {
+		t.Error(s)
+	}
+	n, _ = FromXML([]byte(`<p class="x">Hello <b>world</b> again</p>`), XMLOptions{})
+	if s := n.String(); s != `{"p":{"#text":"Hello  again","@class":"x","b":"world"}}` {
+		t.Error(s)
+	}
+}
+
+func TestFromXMLBadgerFish(t *testing.T) {
+	n, err := FromXML([]byte(`<alice xmlns="http://some-namespace" xmlns:charlie="http://some-other-namespace">`+
+		`<bob>david</bob><charlie:edgar>frank</charlie:edgar><bob>x</bob><empty/></alice>`),
+		XMLOptions{Convention: XMLBadgerFish})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := n.String(); s != `{"alice":{"@xmlns":{"$":"http://some-namespace","charlie":"http://some-other-namespace"},`+
+		`"bob":[{"$":"david"},{"$":"x"}],"charlie:edgar":{"$":"frank"},"empty":{}}}` {
+		t.Error(s)
+	}
+	data, err := n.ToXML(XMLOptions{Convention: XMLBadgerFish})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(data); s != `<alice xmlns="http://some-namespace" xmlns:charlie="http://some-other-namespace">`+
+		`<bob>david</bob><bob>x</bob><charlie:edgar>frank</charlie:edgar><empty/></alice>` {
+		t.Error(s)
+	}
+}
+
+func TestFromXMLParker(t *testing.T) {
+	n, err := FromXML([]byte(xmlFeed), XMLOptions{Convention: XMLParker})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := n.String(); s != `{"channel":{"empty":null,"item":{"count":3,"title":"One"},"title":"News \u0026 views"}}` {
+		t.Error(s)
+	}
+	data, err := n.ToXML(XMLOptions{Convention: XMLParker, Root: "rss", Indent: "  "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<rss>
+  <channel>
+    <empty/>
+    <item>
+      <count>3</count>
+      <title>One</title>
+    </item>
+    <title>News &amp; views</title>
+  </channel>
+</rss>
+`
+	if string(data) != expected {
+		t.Error(string(data))
+	}
+}
+
+func TestXMLNamespaces(t *testing.T) {
+	soap := `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/">` +
+		`<s:Body><m:Price xmlns:m="urn:prices" currency="USD" m:kind="net">10</m:Price></s:Body></s:Envelope>`
+	n, err := FromXML([]byte(soap), XMLOptions{StripNamespaces: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := n.String(); s != `{"Envelope":{"Body":{"Price":{"#text":"10","@currency":"USD","@kind":"net"}}}}` {
+		t.Error(s)
+	}
+	n, err = FromXML([]byte(soap), XMLOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := n.String(); s != `{"s:Envelope":{"@xmlns:s":"http://schemas.xmlsoap.org/soap/envelope/",`+
+		`"s:Body":{"m:Price":{"#text":"10","@currency":"USD","@m:kind":"net","@xmlns:m":"urn:prices"}}}}` {
+		t.Error(s)
+	}
+	opts := XMLOptions{Namespaces: map[string]string{"http://schemas.xmlsoap.org/soap/envelope/": "soap", "urn:prices": ""}}
+	n, err = FromXML([]byte(soap), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := n.String(); s != `{"soap:Envelope":{"soap:Body":{"Price":{"#text":"10","@currency":"USD","@kind":"net"}}}}` {
+		t.Error(s)
+	}
+	data, err := n.ToXML(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(data); s != `<soap:Envelope xmlns="urn:prices" xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">`+
+		`<soap:Body><Price currency="USD" kind="net">10</Price></soap:Body></soap:Envelope>` {
+		t.Error(s)
+	}
+}
+
+func TestToXMLAttributes(t *testing.T) {
+	n := mustJSON(t, `{"doc": {"@id": 7, "#text": "a<b", "item": [1, "two", null, {"@x": "\""}], "flag": true}}`)
+	data, err := n.ToXML(XMLOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(data); s != `<doc id="7">a&lt;b<flag>true</flag><item>1</item><item>two</item><item/><item x="&#34;"/></doc>` {
+		t.Error(s)
+	}
+	m, err := FromXML(data, XMLOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := m.String(); s != `{"doc":{"#text":"a\u003cb","@id":"7","flag":"true","item":["1","two",null,{"@x":"\""}]}}` {
+		t.Error(s)
+	}
+}
+
+func TestXMLErrors(t *testing.T) {
+	for _, in := range []string{"", "<a>", "<a></b>", "<a/><b/>", "text<a/>", "<a><b></a>", "</a>", "<a></a></b>"} {
+		for _, c := range []XMLConvention{XMLAttributes, XMLBadgerFish, XMLParker} {
+			if _, err := FromXML([]byte(in), XMLOptions{Convention: c}); err == nil {
+				t.Errorf("%q (%s): expected error", in, c)
+			}
+		}
+	}
+	if _, err := FromXML([]byte("<a></a></b>"), XMLOptions{}); err == nil || err.Error() != "xml: unexpected </b>" {
+		t.Error(err)
+	}
+	tests := map[string]string{
+		`{"a": 1, "b": 2}`:    "requires an Object with one field",
+		`{"a b": 1}`:          "not a valid element name",
+		`{"a": [[1]]}`:        "nested array",
+		`{"a": {"@x": [1]}}`:  "must be a scalar",
+		`{"a": [1, 2]}`:       "single element",
+		`{"a": {"@1x": "y"}}`: "not a valid attribute name",
+	}
+	for in, msg := range tests {
+		_, err := mustJSON(t, in).ToXML(XMLOptions{})
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Errorf("%s: %v", in, err)
+		}
+	}
+}